@@ -8,4 +8,5 @@ import (
 type CategoryCacheService interface {
 	GetById(ctx context.Context, id int64) (*model_cache.Category, error)
 	InvalidateById(ctx context.Context, id int64) error
+	RemoveById(id int64)
 }
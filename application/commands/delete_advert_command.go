@@ -0,0 +1,5 @@
+package commands
+
+type DeleteAdvert struct {
+	Id int64
+}
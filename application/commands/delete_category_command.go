@@ -0,0 +1,5 @@
+package commands
+
+type DeleteCategory struct {
+	Id int64
+}
@@ -0,0 +1,5 @@
+package commands
+
+type IndexAdverts struct {
+	Ids []int64
+}
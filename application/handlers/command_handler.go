@@ -3,6 +3,9 @@ package handlers
 import "presentation-advert-consumer/application/commands"
 
 type CommandHandler struct {
-	IndexCategory CommandHandlerDecorator[*commands.IndexCategory, error]
-	IndexAdvert   CommandHandlerDecorator[*commands.IndexAdvert, error]
+	IndexCategory  CommandHandlerDecorator[*commands.IndexCategory, error]
+	IndexAdvert    CommandHandlerDecorator[*commands.IndexAdvert, error]
+	IndexAdverts   CommandHandlerDecorator[*commands.IndexAdverts, map[int64]error]
+	DeleteCategory CommandHandlerDecorator[*commands.DeleteCategory, error]
+	DeleteAdvert   CommandHandlerDecorator[*commands.DeleteAdvert, error]
 }
@@ -0,0 +1,70 @@
+package command_handlers
+
+import (
+	"presentation-advert-consumer/model/model_repository"
+	"slices"
+)
+
+type changedField struct {
+	name    string
+	changed bool
+}
+
+// getAdvertChangedFields lists the fields that differ from the indexed document, all of them when there is no previous document.
+func getAdvertChangedFields(previous *model_repository.Advert, current *model_repository.Advert) []string {
+	if previous == nil {
+		return collectChangedFields(getAdvertFields(&model_repository.Advert{}, current), true)
+	}
+	return collectChangedFields(getAdvertFields(previous, current), false)
+}
+
+func getAdvertFields(previous *model_repository.Advert, current *model_repository.Advert) []changedField {
+	return []changedField{
+		{name: "title", changed: previous.Title != current.Title},
+		{name: "description", changed: previous.Description != current.Description},
+		{name: "version", changed: previous.Version != current.Version},
+		{name: "category", changed: previous.Category != current.Category},
+		{name: "createdBy", changed: previous.CreatedBy != current.CreatedBy},
+		{name: "creationDate", changed: previous.CreationDate != current.CreationDate},
+		{name: "modifiedBy", changed: previous.ModifiedBy != current.ModifiedBy},
+		{name: "lastModifiedDate", changed: previous.LastModifiedDate != current.LastModifiedDate},
+	}
+}
+
+func getCategoryChangedFields(previous *model_repository.Category, current *model_repository.Category) []string {
+	if previous == nil {
+		return collectChangedFields(getCategoryFields(&model_repository.Category{}, current), true)
+	}
+	return collectChangedFields(getCategoryFields(previous, current), false)
+}
+
+func getCategoryFields(previous *model_repository.Category, current *model_repository.Category) []changedField {
+	return []changedField{
+		{name: "name", changed: previous.Name != current.Name},
+		{name: "version", changed: previous.Version != current.Version},
+		{name: "createdBy", changed: previous.CreatedBy != current.CreatedBy},
+		{name: "creationDate", changed: previous.CreationDate != current.CreationDate},
+		{name: "modifiedBy", changed: previous.ModifiedBy != current.ModifiedBy},
+		{name: "lastModifiedDate", changed: previous.LastModifiedDate != current.LastModifiedDate},
+	}
+}
+
+func collectChangedFields(fields []changedField, all bool) []string {
+	changedFields := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if all || field.changed {
+			changedFields = append(changedFields, field.name)
+		}
+	}
+	return changedFields
+}
+
+// mergeUnpublishedFields adds the fields whose event could not be published for an earlier change.
+func mergeUnpublishedFields(changedFields []string, unpublishedFields []string) []string {
+	for _, field := range unpublishedFields {
+		if !slices.Contains(changedFields, field) {
+			changedFields = append(changedFields, field)
+		}
+	}
+	return changedFields
+}
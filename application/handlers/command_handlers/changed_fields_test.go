@@ -0,0 +1,124 @@
+package command_handlers
+
+import (
+	"presentation-advert-consumer/model/model_repository"
+	"slices"
+	"testing"
+)
+
+func TestGetAdvertChangedFields(t *testing.T) {
+	current := &model_repository.Advert{
+		Id:               1,
+		Title:            "title",
+		Description:      "description",
+		Version:          2,
+		Category:         model_repository.AdvertCategory{Id: 3, Name: "category"},
+		CreatedBy:        "creator",
+		CreationDate:     "2024-01-01",
+		ModifiedBy:       "modifier",
+		LastModifiedDate: "2024-01-02",
+	}
+	tests := []struct {
+		name     string
+		previous *model_repository.Advert
+		expected []string
+	}{
+		{
+			name:     "no previous document",
+			previous: nil,
+			expected: []string{"title", "description", "version", "category", "createdBy", "creationDate", "modifiedBy", "lastModifiedDate"},
+		},
+		{
+			name:     "nothing changed",
+			previous: current,
+			expected: []string{},
+		},
+		{
+			name: "title and version changed",
+			previous: &model_repository.Advert{
+				Id: 1, Title: "old title", Description: "description", Version: 1, Category: current.Category,
+				CreatedBy: "creator", CreationDate: "2024-01-01", ModifiedBy: "modifier", LastModifiedDate: "2024-01-02",
+			},
+			expected: []string{"title", "version"},
+		},
+		{
+			name: "category changed",
+			previous: &model_repository.Advert{
+				Id: 1, Title: "title", Description: "description", Version: 2, Category: model_repository.AdvertCategory{Id: 3, Name: "old category"},
+				CreatedBy: "creator", CreationDate: "2024-01-01", ModifiedBy: "modifier", LastModifiedDate: "2024-01-02",
+			},
+			expected: []string{"category"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := getAdvertChangedFields(test.previous, current); !slices.Equal(actual, test.expected) {
+				t.Errorf("changed fields = %v, expected %v", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestGetCategoryChangedFields(t *testing.T) {
+	current := &model_repository.Category{
+		Id:               1,
+		Name:             "name",
+		Version:          2,
+		CreatedBy:        "creator",
+		CreationDate:     "2024-01-01",
+		ModifiedBy:       "modifier",
+		LastModifiedDate: "2024-01-02",
+	}
+	tests := []struct {
+		name     string
+		previous *model_repository.Category
+		expected []string
+	}{
+		{
+			name:     "no previous document",
+			previous: nil,
+			expected: []string{"name", "version", "createdBy", "creationDate", "modifiedBy", "lastModifiedDate"},
+		},
+		{
+			name:     "nothing changed",
+			previous: current,
+			expected: []string{},
+		},
+		{
+			name: "modification changed",
+			previous: &model_repository.Category{
+				Id: 1, Name: "name", Version: 2, CreatedBy: "creator", CreationDate: "2024-01-01", ModifiedBy: "other", LastModifiedDate: "2024-01-01",
+			},
+			expected: []string{"modifiedBy", "lastModifiedDate"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := getCategoryChangedFields(test.previous, current); !slices.Equal(actual, test.expected) {
+				t.Errorf("changed fields = %v, expected %v", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestMergeUnpublishedFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		changed     []string
+		unpublished []string
+		expected    []string
+	}{
+		{name: "nothing unpublished", changed: []string{"title"}, unpublished: nil, expected: []string{"title"}},
+		{name: "nothing changed", changed: []string{}, unpublished: []string{"title"}, expected: []string{"title"}},
+		{name: "distinct fields", changed: []string{"title"}, unpublished: []string{"version"}, expected: []string{"title", "version"}},
+		{name: "overlapping fields", changed: []string{"title", "version"}, unpublished: []string{"version", "category"}, expected: []string{"title", "version", "category"}},
+		{name: "both empty", changed: []string{}, unpublished: []string{}, expected: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := mergeUnpublishedFields(test.changed, test.unpublished); !slices.Equal(actual, test.expected) {
+				t.Errorf("merged fields = %v, expected %v", actual, test.expected)
+			}
+		})
+	}
+}
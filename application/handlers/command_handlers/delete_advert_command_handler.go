@@ -0,0 +1,24 @@
+package command_handlers
+
+import (
+	"context"
+	"presentation-advert-consumer/application/commands"
+	"presentation-advert-consumer/application/handlers"
+	"presentation-advert-consumer/application/repository"
+)
+
+type deleteAdvertCommandHandler struct {
+	advertRepository repository.AdvertRepository
+}
+
+func NewDeleteAdvertCommandHandler(
+	advertRepository repository.AdvertRepository,
+) handlers.CommandHandlerInterface[*commands.DeleteAdvert, error] {
+	return &deleteAdvertCommandHandler{
+		advertRepository: advertRepository,
+	}
+}
+
+func (handler *deleteAdvertCommandHandler) Handle(ctx context.Context, command *commands.DeleteAdvert) error {
+	return handler.advertRepository.Delete(ctx, command.Id)
+}
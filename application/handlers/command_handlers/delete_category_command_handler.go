@@ -0,0 +1,32 @@
+package command_handlers
+
+import (
+	"context"
+	"presentation-advert-consumer/application/cacheservice"
+	"presentation-advert-consumer/application/commands"
+	"presentation-advert-consumer/application/handlers"
+	"presentation-advert-consumer/application/repository"
+)
+
+type deleteCategoryCommandHandler struct {
+	categoryRepository   repository.CategoryRepository
+	categoryCacheService cacheservice.CategoryCacheService
+}
+
+func NewDeleteCategoryCommandHandler(
+	categoryRepository repository.CategoryRepository,
+	categoryCacheService cacheservice.CategoryCacheService,
+) handlers.CommandHandlerInterface[*commands.DeleteCategory, error] {
+	return &deleteCategoryCommandHandler{
+		categoryRepository:   categoryRepository,
+		categoryCacheService: categoryCacheService,
+	}
+}
+
+func (handler *deleteCategoryCommandHandler) Handle(ctx context.Context, command *commands.DeleteCategory) error {
+	if err := handler.categoryRepository.Delete(ctx, command.Id); err != nil {
+		return err
+	}
+	handler.categoryCacheService.RemoveById(command.Id)
+	return nil
+}
@@ -6,25 +6,34 @@ import (
 	"presentation-advert-consumer/application/client"
 	"presentation-advert-consumer/application/commands"
 	"presentation-advert-consumer/application/handlers"
+	"presentation-advert-consumer/application/publisher"
 	"presentation-advert-consumer/application/repository"
+	"presentation-advert-consumer/model/model_event"
 	"presentation-advert-consumer/model/model_repository"
+	"time"
 )
 
 type indexAdvertCommandHandler struct {
-	advertApiClient      client.AdvertApiClient
-	advertRepository     repository.AdvertRepository
-	categoryCacheService cacheservice.CategoryCacheService
+	advertApiClient             client.AdvertApiClient
+	advertRepository            repository.AdvertRepository
+	unpublishedFieldsRepository repository.UnpublishedFieldsRepository
+	categoryCacheService        cacheservice.CategoryCacheService
+	eventPublisher              publisher.IndexedEventPublisher
 }
 
 func NewIndexAdvertCommandHandler(
 	advertApiClient client.AdvertApiClient,
 	advertRepository repository.AdvertRepository,
+	unpublishedFieldsRepository repository.UnpublishedFieldsRepository,
 	categoryCacheService cacheservice.CategoryCacheService,
+	eventPublisher publisher.IndexedEventPublisher,
 ) handlers.CommandHandlerInterface[*commands.IndexAdvert, error] {
 	return &indexAdvertCommandHandler{
-		advertApiClient:      advertApiClient,
-		advertRepository:     advertRepository,
-		categoryCacheService: categoryCacheService,
+		advertApiClient:             advertApiClient,
+		advertRepository:            advertRepository,
+		unpublishedFieldsRepository: unpublishedFieldsRepository,
+		categoryCacheService:        categoryCacheService,
+		eventPublisher:              eventPublisher,
 	}
 }
 
@@ -55,5 +64,34 @@ func (handler *indexAdvertCommandHandler) Handle(ctx context.Context, command *c
 			ModifiedBy:       categoryResponse.ModifiedBy,
 			LastModifiedDate: categoryResponse.LastModifiedDate},
 	}
-	return handler.advertRepository.Save(ctx, advert)
+	// the previous document is only used to find the changed fields, a failed lookup reports every field as changed
+	previous, _ := handler.advertRepository.GetById(ctx, advert.Id)
+	unpublishedFields, err := handler.unpublishedFieldsRepository.GetByIds(ctx, model_repository.AdvertUnpublishedFields, []int64{advert.Id})
+	if err != nil {
+		return err
+	}
+	changedFields := mergeUnpublishedFields(getAdvertChangedFields(previous, advert), unpublishedFields[advert.Id])
+	// the changed fields are kept until the event is published, so a retry publishes them again
+	if len(changedFields) > len(unpublishedFields[advert.Id]) {
+		failedIds := handler.unpublishedFieldsRepository.SaveAll(ctx, model_repository.AdvertUnpublishedFields, map[int64][]string{advert.Id: changedFields})
+		if err, failed := failedIds[advert.Id]; failed {
+			return err
+		}
+	}
+	if err := handler.advertRepository.Save(ctx, advert); err != nil {
+		return err
+	}
+	if len(changedFields) == 0 {
+		return nil
+	}
+	err = handler.eventPublisher.PublishAdvertIndexed(ctx, &model_event.AdvertIndexed{
+		Id:            advert.Id,
+		Version:       advert.Version,
+		IndexedAt:     time.Now(),
+		ChangedFields: changedFields,
+	})
+	if err != nil {
+		return err
+	}
+	return handler.unpublishedFieldsRepository.DeleteAll(ctx, model_repository.AdvertUnpublishedFields, []int64{advert.Id})
 }
@@ -0,0 +1,145 @@
+package command_handlers
+
+import (
+	"context"
+	"presentation-advert-consumer/application/cacheservice"
+	"presentation-advert-consumer/application/client"
+	"presentation-advert-consumer/application/commands"
+	"presentation-advert-consumer/application/handlers"
+	"presentation-advert-consumer/application/publisher"
+	"presentation-advert-consumer/application/repository"
+	"presentation-advert-consumer/model/model_event"
+	"presentation-advert-consumer/model/model_repository"
+	"time"
+)
+
+type indexAdvertsCommandHandler struct {
+	advertApiClient             client.AdvertApiClient
+	advertRepository            repository.AdvertRepository
+	unpublishedFieldsRepository repository.UnpublishedFieldsRepository
+	categoryCacheService        cacheservice.CategoryCacheService
+	eventPublisher              publisher.IndexedEventPublisher
+}
+
+func NewIndexAdvertsCommandHandler(
+	advertApiClient client.AdvertApiClient,
+	advertRepository repository.AdvertRepository,
+	unpublishedFieldsRepository repository.UnpublishedFieldsRepository,
+	categoryCacheService cacheservice.CategoryCacheService,
+	eventPublisher publisher.IndexedEventPublisher,
+) handlers.CommandHandlerInterface[*commands.IndexAdverts, map[int64]error] {
+	return &indexAdvertsCommandHandler{
+		advertApiClient:             advertApiClient,
+		advertRepository:            advertRepository,
+		unpublishedFieldsRepository: unpublishedFieldsRepository,
+		categoryCacheService:        categoryCacheService,
+		eventPublisher:              eventPublisher,
+	}
+}
+
+func (handler *indexAdvertsCommandHandler) Handle(ctx context.Context, command *commands.IndexAdverts) map[int64]error {
+	failedIds := make(map[int64]error)
+	adverts := make([]*model_repository.Advert, 0, len(command.Ids))
+	for _, id := range command.Ids {
+		advertResponse, err := handler.advertApiClient.GetAdvertById(ctx, id)
+		if err != nil {
+			failedIds[id] = err
+			continue
+		}
+		categoryResponse, err := handler.categoryCacheService.GetById(ctx, advertResponse.CategoryId)
+		if err != nil {
+			failedIds[id] = err
+			continue
+		}
+		adverts = append(adverts, &model_repository.Advert{
+			Id:               advertResponse.Id,
+			Title:            advertResponse.Title,
+			Description:      advertResponse.Description,
+			Version:          advertResponse.Version,
+			CreatedBy:        advertResponse.CreatedBy,
+			CreationDate:     advertResponse.CreationDate,
+			ModifiedBy:       advertResponse.ModifiedBy,
+			LastModifiedDate: advertResponse.LastModifiedDate,
+			Category: model_repository.AdvertCategory{
+				Id:               categoryResponse.Id,
+				Name:             categoryResponse.Name,
+				Version:          categoryResponse.Version,
+				CreatedBy:        categoryResponse.CreatedBy,
+				CreationDate:     categoryResponse.CreationDate,
+				ModifiedBy:       categoryResponse.ModifiedBy,
+				LastModifiedDate: categoryResponse.LastModifiedDate},
+		})
+	}
+	if len(adverts) == 0 {
+		return failedIds
+	}
+	ids := make([]int64, 0, len(adverts))
+	for _, advert := range adverts {
+		ids = append(ids, advert.Id)
+	}
+	// the previous documents are only used to find the changed fields, a failed lookup reports every field as changed
+	previousAdverts, err := handler.advertRepository.GetByIds(ctx, ids)
+	if err != nil {
+		previousAdverts = make(map[int64]*model_repository.Advert)
+	}
+	unpublishedFields, err := handler.unpublishedFieldsRepository.GetByIds(ctx, model_repository.AdvertUnpublishedFields, ids)
+	if err != nil {
+		for _, id := range ids {
+			failedIds[id] = err
+		}
+		return failedIds
+	}
+	changedFields := make(map[int64][]string, len(adverts))
+	newUnpublishedFields := make(map[int64][]string)
+	for _, advert := range adverts {
+		changedFields[advert.Id] = mergeUnpublishedFields(getAdvertChangedFields(previousAdverts[advert.Id], advert), unpublishedFields[advert.Id])
+		if len(changedFields[advert.Id]) > len(unpublishedFields[advert.Id]) {
+			newUnpublishedFields[advert.Id] = changedFields[advert.Id]
+		}
+	}
+	// the changed fields are kept until the events are published, so a retry publishes them again
+	for id, err := range handler.unpublishedFieldsRepository.SaveAll(ctx, model_repository.AdvertUnpublishedFields, newUnpublishedFields) {
+		failedIds[id] = err
+	}
+	savableAdverts := make([]*model_repository.Advert, 0, len(adverts))
+	for _, advert := range adverts {
+		if _, failed := failedIds[advert.Id]; !failed {
+			savableAdverts = append(savableAdverts, advert)
+		}
+	}
+	// only the adverts rejected by the store are failed, the others are published
+	for id, err := range handler.advertRepository.SaveAll(ctx, savableAdverts) {
+		failedIds[id] = err
+	}
+	indexedAt := time.Now()
+	events := make([]*model_event.AdvertIndexed, 0, len(savableAdverts))
+	for _, advert := range savableAdverts {
+		if _, failed := failedIds[advert.Id]; failed || len(changedFields[advert.Id]) == 0 {
+			continue
+		}
+		events = append(events, &model_event.AdvertIndexed{
+			Id:            advert.Id,
+			Version:       advert.Version,
+			IndexedAt:     indexedAt,
+			ChangedFields: changedFields[advert.Id],
+		})
+	}
+	publishFailedIds := handler.eventPublisher.PublishAdvertsIndexed(ctx, events)
+	publishedIds := make([]int64, 0, len(events))
+	for _, event := range events {
+		if err, failed := publishFailedIds[event.Id]; failed {
+			failedIds[event.Id] = err
+			continue
+		}
+		publishedIds = append(publishedIds, event.Id)
+	}
+	if len(publishedIds) == 0 {
+		return failedIds
+	}
+	if err := handler.unpublishedFieldsRepository.DeleteAll(ctx, model_repository.AdvertUnpublishedFields, publishedIds); err != nil {
+		for _, id := range publishedIds {
+			failedIds[id] = err
+		}
+	}
+	return failedIds
+}
@@ -0,0 +1,278 @@
+package command_handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"presentation-advert-consumer/application/commands"
+	"presentation-advert-consumer/model/model_cache"
+	"presentation-advert-consumer/model/model_client"
+	"presentation-advert-consumer/model/model_event"
+	"presentation-advert-consumer/model/model_repository"
+	"slices"
+	"sort"
+	"testing"
+)
+
+type fakeAdvertApiClient struct {
+	failedIds map[int64]struct{}
+}
+
+func (client *fakeAdvertApiClient) GetAdvertById(_ context.Context, id int64) (*model_client.AdvertResponse, error) {
+	if _, failed := client.failedIds[id]; failed {
+		return nil, fmt.Errorf("advert api failed, id: %d", id)
+	}
+	return &model_client.AdvertResponse{Id: id, Title: "title", CategoryId: 1, Version: 1}, nil
+}
+
+func (client *fakeAdvertApiClient) GetCategoryById(_ context.Context, id int64) (*model_client.CategoryResponse, error) {
+	return &model_client.CategoryResponse{Id: id, Name: "category"}, nil
+}
+
+type fakeCategoryCacheService struct{}
+
+func (service *fakeCategoryCacheService) GetById(_ context.Context, id int64) (*model_cache.Category, error) {
+	return &model_cache.Category{Id: id, Name: "category"}, nil
+}
+
+func (service *fakeCategoryCacheService) InvalidateById(context.Context, int64) error {
+	return nil
+}
+
+func (service *fakeCategoryCacheService) RemoveById(int64) {}
+
+type fakeAdvertRepository struct {
+	previous  map[int64]*model_repository.Advert
+	failedIds map[int64]struct{}
+	saved     []int64
+}
+
+func (repository *fakeAdvertRepository) Save(context.Context, *model_repository.Advert) error {
+	return nil
+}
+
+func (repository *fakeAdvertRepository) SaveAll(_ context.Context, models []*model_repository.Advert) map[int64]error {
+	failedIds := make(map[int64]error)
+	for _, model := range models {
+		if _, failed := repository.failedIds[model.Id]; failed {
+			failedIds[model.Id] = fmt.Errorf("save failed, id: %d", model.Id)
+			continue
+		}
+		repository.saved = append(repository.saved, model.Id)
+	}
+	return failedIds
+}
+
+func (repository *fakeAdvertRepository) GetById(_ context.Context, id int64) (*model_repository.Advert, error) {
+	return repository.previous[id], nil
+}
+
+func (repository *fakeAdvertRepository) GetByIds(context.Context, []int64) (map[int64]*model_repository.Advert, error) {
+	return repository.previous, nil
+}
+
+func (repository *fakeAdvertRepository) Delete(context.Context, int64) error {
+	return nil
+}
+
+type fakeUnpublishedFieldsRepository struct {
+	fields   map[int64][]string
+	getErr   error
+	saveErrs map[int64]struct{}
+}
+
+func (repository *fakeUnpublishedFieldsRepository) GetByIds(_ context.Context, _ model_repository.UnpublishedFieldsType, ids []int64) (map[int64][]string, error) {
+	if repository.getErr != nil {
+		return nil, repository.getErr
+	}
+	fields := make(map[int64][]string)
+	for _, id := range ids {
+		if entityFields, exists := repository.fields[id]; exists {
+			fields[id] = entityFields
+		}
+	}
+	return fields, nil
+}
+
+func (repository *fakeUnpublishedFieldsRepository) SaveAll(_ context.Context, _ model_repository.UnpublishedFieldsType, fields map[int64][]string) map[int64]error {
+	failedIds := make(map[int64]error)
+	for id, entityFields := range fields {
+		if _, failed := repository.saveErrs[id]; failed {
+			failedIds[id] = fmt.Errorf("unpublished fields save failed, id: %d", id)
+			continue
+		}
+		repository.fields[id] = entityFields
+	}
+	return failedIds
+}
+
+func (repository *fakeUnpublishedFieldsRepository) DeleteAll(_ context.Context, _ model_repository.UnpublishedFieldsType, ids []int64) error {
+	for _, id := range ids {
+		delete(repository.fields, id)
+	}
+	return nil
+}
+
+type fakeIndexedEventPublisher struct {
+	failedIds map[int64]struct{}
+	published map[int64][]string
+}
+
+func (p *fakeIndexedEventPublisher) PublishAdvertIndexed(context.Context, *model_event.AdvertIndexed) error {
+	return nil
+}
+
+func (p *fakeIndexedEventPublisher) PublishAdvertsIndexed(_ context.Context, events []*model_event.AdvertIndexed) map[int64]error {
+	failedIds := make(map[int64]error)
+	for _, event := range events {
+		if _, failed := p.failedIds[event.Id]; failed {
+			failedIds[event.Id] = fmt.Errorf("publish failed, id: %d", event.Id)
+			continue
+		}
+		p.published[event.Id] = event.ChangedFields
+	}
+	return failedIds
+}
+
+func (p *fakeIndexedEventPublisher) PublishCategoryIndexed(context.Context, *model_event.CategoryIndexed) error {
+	return nil
+}
+
+func toIdSet(ids []int64) map[int64]struct{} {
+	set := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
+
+func getSortedIds[T any](idMap map[int64]T) []int64 {
+	ids := make([]int64, 0, len(idMap))
+	for id := range idMap {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestIndexAdvertsCommandHandler_Handle(t *testing.T) {
+	indexed := func(id int64) *model_repository.Advert {
+		return &model_repository.Advert{Id: id, Title: "title", Version: 1, Category: model_repository.AdvertCategory{Id: 1, Name: "category"}}
+	}
+	tests := []struct {
+		name                string
+		previous            map[int64]*model_repository.Advert
+		unpublished         map[int64][]string
+		unpublishedGetErr   error
+		apiFailedIds        []int64
+		unpublishedSaveErrs []int64
+		saveFailedIds       []int64
+		publishFailedIds    []int64
+		expectedFailedIds   []int64
+		expectedSaved       []int64
+		expectedPublished   map[int64][]string
+		expectedUnpublished map[int64][]string
+	}{
+		{
+			name:                "unchanged adverts are saved without events",
+			previous:            map[int64]*model_repository.Advert{1: indexed(1), 2: indexed(2), 3: indexed(3)},
+			unpublished:         map[int64][]string{},
+			expectedFailedIds:   []int64{},
+			expectedSaved:       []int64{1, 2, 3},
+			expectedPublished:   map[int64][]string{},
+			expectedUnpublished: map[int64][]string{},
+		},
+		{
+			name:                "changed fields are published and pending fields merged",
+			previous:            map[int64]*model_repository.Advert{1: {Id: 1, Title: "old", Version: 1, Category: indexed(1).Category}, 2: indexed(2), 3: indexed(3)},
+			unpublished:         map[int64][]string{2: {"description"}},
+			expectedFailedIds:   []int64{},
+			expectedSaved:       []int64{1, 2, 3},
+			expectedPublished:   map[int64][]string{1: {"title"}, 2: {"description"}},
+			expectedUnpublished: map[int64][]string{},
+		},
+		{
+			name:                "only adverts rejected by the store fail",
+			previous:            map[int64]*model_repository.Advert{},
+			unpublished:         map[int64][]string{},
+			saveFailedIds:       []int64{2},
+			expectedFailedIds:   []int64{2},
+			expectedSaved:       []int64{1, 3},
+			expectedPublished:   map[int64][]string{1: allAdvertFields(), 3: allAdvertFields()},
+			expectedUnpublished: map[int64][]string{2: allAdvertFields()},
+		},
+		{
+			name:                "unpublished events keep their fields",
+			previous:            map[int64]*model_repository.Advert{},
+			unpublished:         map[int64][]string{},
+			publishFailedIds:    []int64{3},
+			expectedFailedIds:   []int64{3},
+			expectedSaved:       []int64{1, 2, 3},
+			expectedPublished:   map[int64][]string{1: allAdvertFields(), 2: allAdvertFields()},
+			expectedUnpublished: map[int64][]string{3: allAdvertFields()},
+		},
+		{
+			name:                "advert whose fields could not be kept is not saved",
+			previous:            map[int64]*model_repository.Advert{},
+			unpublished:         map[int64][]string{},
+			unpublishedSaveErrs: []int64{1},
+			expectedFailedIds:   []int64{1},
+			expectedSaved:       []int64{2, 3},
+			expectedPublished:   map[int64][]string{2: allAdvertFields(), 3: allAdvertFields()},
+			expectedUnpublished: map[int64][]string{},
+		},
+		{
+			name:                "failed api call fails only its advert",
+			previous:            map[int64]*model_repository.Advert{1: indexed(1), 3: indexed(3)},
+			unpublished:         map[int64][]string{},
+			apiFailedIds:        []int64{2},
+			expectedFailedIds:   []int64{2},
+			expectedSaved:       []int64{1, 3},
+			expectedPublished:   map[int64][]string{},
+			expectedUnpublished: map[int64][]string{},
+		},
+		{
+			name:                "unpublished fields read error fails every advert",
+			previous:            map[int64]*model_repository.Advert{},
+			unpublished:         map[int64][]string{},
+			unpublishedGetErr:   errors.New("read failed"),
+			expectedFailedIds:   []int64{1, 2, 3},
+			expectedSaved:       nil,
+			expectedPublished:   map[int64][]string{},
+			expectedUnpublished: map[int64][]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			advertRepository := &fakeAdvertRepository{previous: test.previous, failedIds: toIdSet(test.saveFailedIds)}
+			unpublishedFieldsRepository := &fakeUnpublishedFieldsRepository{fields: test.unpublished, getErr: test.unpublishedGetErr, saveErrs: toIdSet(test.unpublishedSaveErrs)}
+			eventPublisher := &fakeIndexedEventPublisher{failedIds: toIdSet(test.publishFailedIds), published: make(map[int64][]string)}
+			handler := NewIndexAdvertsCommandHandler(
+				&fakeAdvertApiClient{failedIds: toIdSet(test.apiFailedIds)},
+				advertRepository,
+				unpublishedFieldsRepository,
+				&fakeCategoryCacheService{},
+				eventPublisher,
+			)
+
+			failedIds := handler.Handle(context.Background(), &commands.IndexAdverts{Ids: []int64{1, 2, 3}})
+
+			if ids := getSortedIds(failedIds); !slices.Equal(ids, test.expectedFailedIds) {
+				t.Errorf("failed ids = %v, expected %v", ids, test.expectedFailedIds)
+			}
+			if !slices.Equal(advertRepository.saved, test.expectedSaved) {
+				t.Errorf("saved ids = %v, expected %v", advertRepository.saved, test.expectedSaved)
+			}
+			if fmt.Sprint(eventPublisher.published) != fmt.Sprint(test.expectedPublished) {
+				t.Errorf("published fields = %v, expected %v", eventPublisher.published, test.expectedPublished)
+			}
+			if fmt.Sprint(unpublishedFieldsRepository.fields) != fmt.Sprint(test.expectedUnpublished) {
+				t.Errorf("unpublished fields = %v, expected %v", unpublishedFieldsRepository.fields, test.expectedUnpublished)
+			}
+		})
+	}
+}
+
+func allAdvertFields() []string {
+	return []string{"title", "description", "version", "category", "createdBy", "creationDate", "modifiedBy", "lastModifiedDate"}
+}
@@ -5,23 +5,31 @@ import (
 	"presentation-advert-consumer/application/client"
 	"presentation-advert-consumer/application/commands"
 	"presentation-advert-consumer/application/handlers"
+	"presentation-advert-consumer/application/publisher"
 	"presentation-advert-consumer/application/repository"
+	"presentation-advert-consumer/model/model_event"
 	"presentation-advert-consumer/model/model_repository"
 	"time"
 )
 
 type indexCategoryCommandHandler struct {
-	advertApiClient    client.AdvertApiClient
-	categoryRepository repository.CategoryRepository
+	advertApiClient             client.AdvertApiClient
+	categoryRepository          repository.CategoryRepository
+	unpublishedFieldsRepository repository.UnpublishedFieldsRepository
+	eventPublisher              publisher.IndexedEventPublisher
 }
 
 func NewIndexCategoryCommandHandler(
 	advertApiClient client.AdvertApiClient,
 	categoryRepository repository.CategoryRepository,
+	unpublishedFieldsRepository repository.UnpublishedFieldsRepository,
+	eventPublisher publisher.IndexedEventPublisher,
 ) handlers.CommandHandlerInterface[*commands.IndexCategory, error] {
 	return &indexCategoryCommandHandler{
-		advertApiClient:    advertApiClient,
-		categoryRepository: categoryRepository,
+		advertApiClient:             advertApiClient,
+		categoryRepository:          categoryRepository,
+		unpublishedFieldsRepository: unpublishedFieldsRepository,
+		eventPublisher:              eventPublisher,
 	}
 }
 
@@ -40,5 +48,32 @@ func (handler *indexCategoryCommandHandler) Handle(ctx context.Context, command
 		LastModifiedDate: categoryResponse.LastModifiedDate,
 		IndexedAt:        time.Now(),
 	}
-	return handler.categoryRepository.Save(ctx, category)
+	previous, _ := handler.categoryRepository.GetById(ctx, category.Id)
+	unpublishedFields, err := handler.unpublishedFieldsRepository.GetByIds(ctx, model_repository.CategoryUnpublishedFields, []int64{category.Id})
+	if err != nil {
+		return err
+	}
+	changedFields := mergeUnpublishedFields(getCategoryChangedFields(previous, category), unpublishedFields[category.Id])
+	if len(changedFields) > len(unpublishedFields[category.Id]) {
+		failedIds := handler.unpublishedFieldsRepository.SaveAll(ctx, model_repository.CategoryUnpublishedFields, map[int64][]string{category.Id: changedFields})
+		if err, failed := failedIds[category.Id]; failed {
+			return err
+		}
+	}
+	if err := handler.categoryRepository.Save(ctx, category); err != nil {
+		return err
+	}
+	if len(changedFields) == 0 {
+		return nil
+	}
+	err = handler.eventPublisher.PublishCategoryIndexed(ctx, &model_event.CategoryIndexed{
+		Id:            category.Id,
+		Version:       category.Version,
+		IndexedAt:     category.IndexedAt,
+		ChangedFields: changedFields,
+	})
+	if err != nil {
+		return err
+	}
+	return handler.unpublishedFieldsRepository.DeleteAll(ctx, model_repository.CategoryUnpublishedFields, []int64{category.Id})
 }
@@ -0,0 +1,13 @@
+package publisher
+
+import (
+	"context"
+	"presentation-advert-consumer/model/model_event"
+)
+
+type IndexedEventPublisher interface {
+	PublishAdvertIndexed(ctx context.Context, event *model_event.AdvertIndexed) error
+	// PublishAdvertsIndexed returns the publish error of every event that could not be published by advert id
+	PublishAdvertsIndexed(ctx context.Context, events []*model_event.AdvertIndexed) map[int64]error
+	PublishCategoryIndexed(ctx context.Context, event *model_event.CategoryIndexed) error
+}
@@ -7,5 +7,9 @@ import (
 
 type AdvertRepository interface {
 	Save(ctx context.Context, model *model_repository.Advert) error
+	// SaveAll returns the save error of every advert that could not be saved by advert id
+	SaveAll(ctx context.Context, models []*model_repository.Advert) map[int64]error
 	GetById(ctx context.Context, id int64) (*model_repository.Advert, error)
+	GetByIds(ctx context.Context, ids []int64) (map[int64]*model_repository.Advert, error)
+	Delete(ctx context.Context, id int64) error
 }
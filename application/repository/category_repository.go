@@ -8,4 +8,5 @@ import (
 type CategoryRepository interface {
 	Save(ctx context.Context, model *model_repository.Category) error
 	GetById(ctx context.Context, id int64) (*model_repository.Category, error)
+	Delete(ctx context.Context, id int64) error
 }
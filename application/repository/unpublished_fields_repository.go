@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+	"presentation-advert-consumer/model/model_repository"
+)
+
+type UnpublishedFieldsRepository interface {
+	GetByIds(ctx context.Context, fieldsType model_repository.UnpublishedFieldsType, ids []int64) (map[int64][]string, error)
+	// SaveAll returns the save error of every entity whose fields could not be saved by entity id
+	SaveAll(ctx context.Context, fieldsType model_repository.UnpublishedFieldsType, fields map[int64][]string) map[int64]error
+	DeleteAll(ctx context.Context, fieldsType model_repository.UnpublishedFieldsType, ids []int64) error
+}
@@ -52,3 +52,7 @@ func (service *categoryCacheService) InvalidateById(ctx context.Context, id int6
 	_, err := service.GetById(ctx, id)
 	return err
 }
+
+func (service *categoryCacheService) RemoveById(id int64) {
+	service.inMemCache.Delete(fmt.Sprint(id))
+}
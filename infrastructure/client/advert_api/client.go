@@ -42,7 +42,7 @@ func (client *advertApiClient) GetAdvertById(ctx context.Context, id int64) (*mo
 		var statusCodeError client_error.HttpStatusCodeError
 		isStatusCodeError := errors.As(err, &statusCodeError)
 		if isStatusCodeError && statusCodeError.StatusCode() == 404 {
-			return nil, custom_error.NotFoundErrWithArgs("Advert not found by id: %s", id)
+			return nil, custom_error.NotFoundErrWithArgs("Advert not found by id: %d", id)
 		}
 		return nil, err
 	}
@@ -55,7 +55,7 @@ func (client *advertApiClient) GetCategoryById(ctx context.Context, id int64) (*
 		var statusCodeError client_error.HttpStatusCodeError
 		isStatusCodeError := errors.As(err, &statusCodeError)
 		if isStatusCodeError && statusCodeError.StatusCode() == 404 {
-			return nil, custom_error.NotFoundErrWithArgs("Category not found by id: %s", id)
+			return nil, custom_error.NotFoundErrWithArgs("Category not found by id: %d", id)
 		}
 		return nil, err
 	}
@@ -8,6 +8,7 @@ import (
 	"presentation-advert-consumer/infrastructure/configuration/kafka"
 	"presentation-advert-consumer/infrastructure/configuration/log"
 	"presentation-advert-consumer/infrastructure/configuration/server"
+	"presentation-advert-consumer/infrastructure/publisher"
 )
 
 const (
@@ -91,6 +92,14 @@ func ReadKafkaProducerTopicConfig(kafkaProducerConfigPath string) kafka.Producer
 	return conf
 }
 
+func ReadPublisherConfig(publisherConfigPath string) *publisher.Config {
+	var conf publisher.Config
+	if err := readFile(&conf, publisherConfigPath); err != nil {
+		log.Panic("Publisher Config file couldn't read")
+	}
+	return &conf
+}
+
 func readFile(conf interface{}, filePath string) error {
 	viper.AddConfigPath(configPath)
 	viper.SetConfigType(yamlConfigType)
@@ -0,0 +1,65 @@
+package custom_error
+
+import (
+	"errors"
+	"net/http"
+)
+
+type ErrorClass string
+
+const (
+	ErrorClassRetryable ErrorClass = "retryable"
+	ErrorClassPermanent ErrorClass = "permanent"
+	ErrorClassSkip      ErrorClass = "skip"
+)
+
+type ClassifiedError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (err *ClassifiedError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *ClassifiedError) Unwrap() error {
+	return err.Err
+}
+
+func NewRetryableErr(err error) error {
+	return &ClassifiedError{Class: ErrorClassRetryable, Err: err}
+}
+
+func NewPermanentErr(err error) error {
+	return &ClassifiedError{Class: ErrorClassPermanent, Err: err}
+}
+
+func NewSkipErr(err error) error {
+	return &ClassifiedError{Class: ErrorClassSkip, Err: err}
+}
+
+// permanentStatuses are the client errors that can not succeed on retry, timeouts and throttling stay retryable.
+var permanentStatuses = map[int]struct{}{
+	http.StatusBadRequest:          {},
+	http.StatusNotFound:            {},
+	http.StatusUnprocessableEntity: {},
+}
+
+// Classify falls back to the http status of custom errors, only not found and validation errors are permanent.
+func Classify(err error) ErrorClass {
+	var classifiedErr *ClassifiedError
+	if errors.As(err, &classifiedErr) {
+		return classifiedErr.Class
+	}
+	var ce *CustomError
+	if errors.As(err, &ce) {
+		if _, permanent := permanentStatuses[ce.Status]; permanent {
+			return ErrorClassPermanent
+		}
+	}
+	return ErrorClassRetryable
+}
+
+func IsRetryable(err error) bool {
+	return Classify(err) == ErrorClassRetryable
+}
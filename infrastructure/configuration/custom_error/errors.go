@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"github.com/labstack/echo/v4"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -13,6 +12,7 @@ const (
 	resourceNotFoundTitle    = "Not found"
 	badRequestFoundTitle     = "Bad request"
 	internalServerErrorTitle = "Internal Server Error"
+	conflictTitle            = "Conflict"
 )
 
 func NewConfigNotFoundErr(configName string) error {
@@ -88,6 +88,10 @@ func InternalServerErrWithArgs(detail string, a ...any) error {
 	return makeCustomErr(http.StatusInternalServerError, fmt.Sprintf(detail, a...), internalServerErrorTitle)
 }
 
+func ConflictErrWithArgs(detail string, a ...any) error {
+	return makeCustomErr(http.StatusConflict, fmt.Sprintf(detail, a...), conflictTitle)
+}
+
 func NotFoundErr(detail string) error {
 	return makeCustomErr(http.StatusNotFound, detail, resourceNotFoundTitle)
 }
@@ -105,22 +109,6 @@ func makeCustomErr(code int, detail string, title string) error {
 	}
 }
 
-func IsNotFoundError(err error) bool {
-	if err == nil {
-		return false
-	}
-	var ce *CustomError
-	if errors.As(err, &ce) {
-		if ce.Status == http.StatusNotFound {
-			return true
-		}
-	}
-	if strings.Contains(err.Error(), "not found") {
-		return true
-	}
-	return false
-}
-
 func IsInternalServerErr(err error) bool {
 	var ce *CustomError
 	if errors.As(err, &ce) {
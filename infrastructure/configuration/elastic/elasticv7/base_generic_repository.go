@@ -5,6 +5,7 @@ import (
 	"github.com/avast/retry-go"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
 	"presentation-advert-consumer/infrastructure/configuration/custom_error"
 	"presentation-advert-consumer/infrastructure/configuration/custom_json"
 	"presentation-advert-consumer/infrastructure/configuration/elastic"
@@ -72,6 +73,50 @@ func (repository *baseGenericRepository[ID, T]) GetById(ctx context.Context, doc
 	return result, err
 }
 
+// GetByIds fetches the documents in one multi get request, documents that are not found are left out of the result.
+func (repository *baseGenericRepository[ID, T]) GetByIds(ctx context.Context, documents []*elastic.GetDocument) (map[ID]*T, error) {
+	result := make(map[ID]*T, len(documents))
+	if len(documents) == 0 {
+		return result, nil
+	}
+	var multiGetResponse elastic.MultiGetResponse
+	err := retry.Do(
+		func() error {
+			req := esapi.MgetRequest{
+				Index: repository.IndexName,
+				Body:  esutil.NewJSONReader(&elastic.MultiGetRequest{Docs: documents}),
+			}
+			response, err := req.Do(ctx, repository.Client)
+			if err != nil {
+				return err
+			}
+			defer response.Body.Close()
+			if response.IsError() {
+				return custom_error.InternalServerErrWithArgs("GetByIds, %s Index returned an error with status code: %d", repository.IndexName, response.StatusCode)
+			}
+			return custom_json.Decode(response.Body, &multiGetResponse)
+		},
+		retry.Context(ctx),
+		retry.RetryIf(isRetryable),
+		retry.Attempts(5),
+		retry.LastErrorOnly(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, document := range multiGetResponse.Docs {
+		if !document.Found {
+			continue
+		}
+		id, mappedHit, err := repository.mapFunc(document)
+		if err != nil {
+			return nil, err
+		}
+		result[id] = mappedHit
+	}
+	return result, nil
+}
+
 func (repository *baseGenericRepository[ID, T]) GetSearchHits(ctx context.Context, query map[string]interface{}) (map[ID]*T, error) {
 	searchResponse, err := repository.Search(ctx, query)
 	if err != nil {
@@ -4,11 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
-	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"presentation-advert-consumer/infrastructure/configuration/custom_json"
 	"presentation-advert-consumer/infrastructure/configuration/elastic"
 	"presentation-advert-consumer/util"
-	"strings"
 )
 
 type bulkIndexer struct {
@@ -32,32 +30,36 @@ func newBulkIndexer(
 	}
 }
 
-func (bi *bulkIndexer) ProcessItems(items []*elastic.BulkIndexerItem) error {
+// ProcessItems sends the items in batches and returns the items rejected by elasticsearch,
+// the error is set when a batch could not be sent, the items of the earlier batches are indexed then.
+func (bi *bulkIndexer) ProcessItems(items []*elastic.BulkIndexerItem) (elastic.BulkFailedItems, error) {
+	failedItems := make(elastic.BulkFailedItems)
 	batch := make([]byte, 0)
 	itemCount := 0
 	for _, action := range items {
 		bytes, err := getActionJSON(action.Id, action.Type, bi.indexName, action.Routing, action.Source, bi.typeName)
 		if err != nil {
-			return err
+			failedItems[string(action.Id)] = err
+			continue
 		}
-		itemCount++
-		if itemCount > bi.batchSizeLimit || len(batch) > bi.batchByteSizeLimit {
-			if err := bi.bulkRequest(batch); err != nil {
-				return err
+		// the batch is sent before the item that would exceed a limit, the item starts the next batch
+		if itemCount >= bi.batchSizeLimit || (itemCount > 0 && len(batch)+len(bytes) > bi.batchByteSizeLimit) {
+			if err := bi.bulkRequest(batch, failedItems); err != nil {
+				return nil, err
 			}
 			batch = batch[:0]
 			itemCount = 0
-			continue
 		}
 		batch = append(batch, bytes...)
+		itemCount++
 	}
 	if len(batch) == 0 {
-		return nil
+		return failedItems, nil
 	}
-	if err := bi.bulkRequest(batch); err != nil {
-		return err
+	if err := bi.bulkRequest(batch, failedItems); err != nil {
+		return nil, err
 	}
-	return nil
+	return failedItems, nil
 }
 
 var (
@@ -100,70 +102,34 @@ func getActionJSON(docID []byte, action elastic.Action, indexName string, routin
 	return meta, nil
 }
 
-func (bi *bulkIndexer) bulkRequest(batch []byte) error {
+func (bi *bulkIndexer) bulkRequest(batch []byte, failedItems elastic.BulkFailedItems) error {
 	reader := bytes.NewReader(batch)
 	r, err := bi.client.Bulk(reader)
 	if err != nil {
 		return err
 	}
-	err = hasResponseError(r)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func hasResponseError(r *esapi.Response) error {
 	if r == nil {
 		return fmt.Errorf("esapi response is nil")
 	}
+	defer r.Body.Close()
 	if r.IsError() {
 		return fmt.Errorf("bulkIndexer request has error %v", r.String())
 	}
-	rb := new(bytes.Buffer)
-
-	defer r.Body.Close()
-	_, err := rb.ReadFrom(r.Body)
-	if err != nil {
-		return err
-	}
-	b := make(map[string]any)
-	err = custom_json.Unmarshal(rb.Bytes(), &b)
-	if err != nil {
+	var response elastic.BulkResponse
+	if err := custom_json.Decode(r.Body, &response); err != nil {
 		return err
 	}
-	hasError, ok := b["errors"].(bool)
-	if !ok || !hasError {
-		return nil
-	}
-	return joinErrors(b)
-}
-
-func joinErrors(body map[string]any) error {
-	var sb strings.Builder
-	sb.WriteString("bulkIndexer request has error. Errors will be listed below:\n")
-
-	items, ok := body["items"].([]any)
-	if !ok {
+	if !response.Errors {
 		return nil
 	}
-
-	for _, i := range items {
-		item, ok := i.(map[string]any)
-		if !ok {
-			continue
-		}
-
-		for _, v := range item {
-			iv, ok := v.(map[string]any)
-			if !ok {
+	for _, item := range response.Items {
+		for action, result := range item {
+			if result == nil || result.Error == nil {
 				continue
 			}
-
-			if iv["error"] != nil {
-				sb.WriteString(fmt.Sprintf("%v\n", i))
-			}
+			failedItems[result.Id] = fmt.Errorf("bulkIndexer %s item has error, id: %s, status: %d, type: %s, reason: %s",
+				action, result.Id, result.Status, result.Error.Type, result.Error.Reason)
 		}
 	}
-	return fmt.Errorf(sb.String())
+	return nil
 }
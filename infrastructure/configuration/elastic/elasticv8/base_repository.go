@@ -172,9 +172,10 @@ func (repository *baseRepository) IndexDocument(ctx context.Context, document *e
 	)
 }
 
-func (repository *baseRepository) IndexDocuments(ctx context.Context, documents []*elastic.IndexDocument) error {
+// IndexDocuments returns the documents rejected by elasticsearch, the error is set when the documents could not be sent.
+func (repository *baseRepository) IndexDocuments(ctx context.Context, documents []*elastic.IndexDocument) (elastic.BulkFailedItems, error) {
 	if len(documents) == 0 {
-		return nil
+		return elastic.BulkFailedItems{}, nil
 	}
 	docs := make([]*elastic.BulkIndexerItem, 0, len(documents))
 	for _, document := range documents {
@@ -183,9 +184,9 @@ func (repository *baseRepository) IndexDocuments(ctx context.Context, documents
 	return repository.bulkIndexer.ProcessItems(docs)
 }
 
-func (repository *baseRepository) DeleteDocuments(ctx context.Context, documents []*elastic.DeleteDocument) error {
+func (repository *baseRepository) DeleteDocuments(ctx context.Context, documents []*elastic.DeleteDocument) (elastic.BulkFailedItems, error) {
 	if len(documents) == 0 {
-		return nil
+		return elastic.BulkFailedItems{}, nil
 	}
 	docs := make([]*elastic.BulkIndexerItem, 0, len(documents))
 	for _, document := range documents {
@@ -374,5 +375,5 @@ func (repository *baseRepository) parseElasticsearchResponse(res *esapi.Response
 }
 
 func isRetryable(err error) bool {
-	return !custom_error.IsNotFoundError(err)
+	return custom_error.IsRetryable(err)
 }
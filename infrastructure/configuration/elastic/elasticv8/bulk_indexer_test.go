@@ -0,0 +1,156 @@
+package elasticv8
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"net/http"
+	"net/http/httptest"
+	"presentation-advert-consumer/infrastructure/configuration/elastic"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// fakeBulkServer answers bulk requests like elasticsearch, the items with a failed id are rejected.
+type fakeBulkServer struct {
+	failedIds    map[string]struct{}
+	statusCode   int
+	requestedIds [][]string
+}
+
+func (server *fakeBulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path != "/_bulk" {
+		_, _ = w.Write([]byte(`{"version":{"number":"8.13.1"},"tagline":"You Know, for Search"}`))
+		return
+	}
+	if server.statusCode != 0 {
+		w.WriteHeader(server.statusCode)
+		_, _ = w.Write([]byte(`{"error":"unavailable"}`))
+		return
+	}
+	ids := make([]string, 0)
+	items := make([]map[string]*elastic.BulkResponseItem, 0)
+	hasErrors := false
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var line map[string]map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		for action, meta := range line {
+			if action != "index" && action != "delete" {
+				continue
+			}
+			id, _ := meta["_id"].(string)
+			ids = append(ids, id)
+			item := &elastic.BulkResponseItem{Id: id, Status: http.StatusOK}
+			if _, failed := server.failedIds[id]; failed {
+				hasErrors = true
+				item.Status = http.StatusBadRequest
+				item.Error = &elastic.BulkResponseItemError{Type: "mapper_parsing_exception", Reason: "failed to parse"}
+			}
+			items = append(items, map[string]*elastic.BulkResponseItem{action: item})
+		}
+	}
+	server.requestedIds = append(server.requestedIds, ids)
+	_ = json.NewEncoder(w).Encode(&elastic.BulkResponse{Errors: hasErrors, Items: items})
+}
+
+func newTestBulkIndexer(t *testing.T, server *fakeBulkServer, batchSizeLimit int) *bulkIndexer {
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{httpServer.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexer := newBulkIndexer(client, "index")
+	indexer.batchSizeLimit = batchSizeLimit
+	return indexer
+}
+
+func TestBulkIndexer_ProcessItems(t *testing.T) {
+	tests := []struct {
+		name             string
+		items            []*elastic.BulkIndexerItem
+		batchSizeLimit   int
+		failedIds        []string
+		statusCode       int
+		expectedErr      bool
+		expectedFailed   []string
+		expectedRequests [][]string
+	}{
+		{
+			name:             "all indexed in one batch",
+			items:            []*elastic.BulkIndexerItem{elastic.NewIndexAction("1", "a", ""), elastic.NewDeleteAction("2", "")},
+			batchSizeLimit:   10,
+			expectedFailed:   []string{},
+			expectedRequests: [][]string{{"1", "2"}},
+		},
+		{
+			name:             "batch size limit splits the items",
+			items:            []*elastic.BulkIndexerItem{elastic.NewIndexAction("1", "a", ""), elastic.NewIndexAction("2", "b", ""), elastic.NewIndexAction("3", "c", "")},
+			batchSizeLimit:   2,
+			expectedFailed:   []string{},
+			expectedRequests: [][]string{{"1", "2"}, {"3"}},
+		},
+		{
+			name:             "only rejected items fail",
+			items:            []*elastic.BulkIndexerItem{elastic.NewIndexAction("1", "a", ""), elastic.NewIndexAction("2", "b", ""), elastic.NewIndexAction("3", "c", "")},
+			batchSizeLimit:   2,
+			failedIds:        []string{"2", "3"},
+			expectedFailed:   []string{"2", "3"},
+			expectedRequests: [][]string{{"1", "2"}, {"3"}},
+		},
+		{
+			name:             "item that can not be marshalled fails alone",
+			items:            []*elastic.BulkIndexerItem{elastic.NewIndexAction("1", make(chan int), ""), elastic.NewIndexAction("2", "b", "")},
+			batchSizeLimit:   10,
+			expectedFailed:   []string{"1"},
+			expectedRequests: [][]string{{"2"}},
+		},
+		{
+			name:           "failed request fails the call",
+			items:          []*elastic.BulkIndexerItem{elastic.NewIndexAction("1", "a", "")},
+			batchSizeLimit: 10,
+			statusCode:     http.StatusBadRequest,
+			expectedErr:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failedIds := make(map[string]struct{})
+			for _, id := range test.failedIds {
+				failedIds[id] = struct{}{}
+			}
+			server := &fakeBulkServer{failedIds: failedIds, statusCode: test.statusCode}
+			indexer := newTestBulkIndexer(t, server, test.batchSizeLimit)
+
+			failedItems, err := indexer.ProcessItems(test.items)
+
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("err = %v, expected error %t", err, test.expectedErr)
+			}
+			if test.expectedErr {
+				return
+			}
+			failed := make([]string, 0, len(failedItems))
+			for id, itemErr := range failedItems {
+				if itemErr == nil {
+					t.Errorf("failed item %s has no error", id)
+				}
+				failed = append(failed, id)
+			}
+			sort.Strings(failed)
+			if !slices.Equal(failed, test.expectedFailed) {
+				t.Errorf("failed items = %v, expected %v", failed, test.expectedFailed)
+			}
+			if fmt.Sprint(server.requestedIds) != fmt.Sprint(test.expectedRequests) {
+				t.Errorf("requests = %v, expected %v", server.requestedIds, test.expectedRequests)
+			}
+		})
+	}
+}
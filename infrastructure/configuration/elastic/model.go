@@ -30,6 +30,25 @@ type BulkIndexerItem struct {
 	Source  interface{}
 }
 
+// BulkFailedItems keeps the error of every item that was rejected in a bulk request by document id.
+type BulkFailedItems map[string]error
+
+type BulkResponse struct {
+	Errors bool                           `json:"errors"`
+	Items  []map[string]*BulkResponseItem `json:"items"`
+}
+
+type BulkResponseItem struct {
+	Id     string                 `json:"_id"`
+	Status int                    `json:"status"`
+	Error  *BulkResponseItemError `json:"error"`
+}
+
+type BulkResponseItemError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
 func NewDeleteAction(id string, routing string) *BulkIndexerItem {
 	return &BulkIndexerItem{
 		Id:      util.ToByte(id),
@@ -57,6 +76,19 @@ type ExistsDocument struct {
 	Routing string `json:"routing"`
 }
 
+type GetDocument struct {
+	Id      string `json:"_id"`
+	Routing string `json:"routing,omitempty"`
+}
+
+type MultiGetRequest struct {
+	Docs []*GetDocument `json:"docs"`
+}
+
+type MultiGetResponse struct {
+	Docs []*SearchHit `json:"docs"`
+}
+
 type GetByDocIdResponse struct {
 	Id      string          `json:"_id"`
 	Routing string          `json:"_routing"`
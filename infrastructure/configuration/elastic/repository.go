@@ -10,8 +10,8 @@ type BaseRepository interface {
 	ExistsById(ctx context.Context, document *ExistsDocument) (bool, error)
 	DeleteById(ctx context.Context, document *DeleteDocument) error
 	IndexDocument(ctx context.Context, document *IndexDocument) error
-	IndexDocuments(ctx context.Context, documents []*IndexDocument) error
-	DeleteDocuments(ctx context.Context, documents []*DeleteDocument) error
+	IndexDocuments(ctx context.Context, documents []*IndexDocument) (BulkFailedItems, error)
+	DeleteDocuments(ctx context.Context, documents []*DeleteDocument) (BulkFailedItems, error)
 	Search(ctx context.Context, query map[string]interface{}) (*SearchResponse, error)
 	SearchWithSize(ctx context.Context, query map[string]interface{}, size int) (*SearchResponse, error)
 }
@@ -19,6 +19,7 @@ type BaseRepository interface {
 type BaseGenericRepository[ID comparable, T any] interface {
 	BaseRepository
 	GetById(ctx context.Context, documentId string, routingId string) (*T, error)
+	GetByIds(ctx context.Context, documents []*GetDocument) (map[ID]*T, error)
 	GetSearchHits(ctx context.Context, query map[string]interface{}) (map[ID]*T, error)
 	GetSearchHitsChannel(ctx context.Context, query map[string]interface{}, scrollSize int, scrollDuration time.Duration) (<-chan map[ID]*T, <-chan error)
 	GetSearchHitsUsingScroll(ctx context.Context, query map[string]interface{}, scrollSize int, scrollDuration time.Duration) (map[ID]*T, error)
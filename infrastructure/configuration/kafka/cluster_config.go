@@ -24,6 +24,7 @@ type ProducerConfig struct {
 	Timeout         time.Duration `json:"timeout"`
 	MaxMessageBytes int           `json:"maxMessageBytes"`
 	Compression     Compression   `json:"compression"`
+	MaxInFlight     int           `json:"maxInFlight"`
 }
 
 type ClusterConfigMap map[string]*ClusterConfig
@@ -49,12 +50,19 @@ func (c ClusterConfigMap) GetConfigWithDefault(name string) (*ClusterConfig, err
 			if config.ProducerConfig.Compression == "" {
 				config.ProducerConfig.Compression = CompressionNone
 			}
+			if config.ProducerConfig.MaxInFlight < 0 {
+				return nil, custom_error.NewErrWithArgs("producer max in flight must be positive, cluster: %s", name)
+			}
+			if config.ProducerConfig.MaxInFlight == 0 {
+				config.ProducerConfig.MaxInFlight = 1000
+			}
 		} else {
 			config.ProducerConfig = &ProducerConfig{
 				RequiredAcks:    WaitForLocal,
 				Timeout:         10 * time.Second,
 				MaxMessageBytes: 1000000,
 				Compression:     CompressionNone,
+				MaxInFlight:     1000,
 			}
 		}
 		return config, nil
@@ -66,6 +74,9 @@ type ErrorConfig struct {
 	GroupId                           string        `json:"groupId"`
 	Cron                              string        `json:"cron"`
 	MaxErrorCount                     int           `json:"maxErrorCount"`
+	ParkingLotTopic                   string        `json:"parkingLotTopic"`
+	StatusTopic                       string        `json:"statusTopic"`
+	RunReportHistorySize              int           `json:"runReportHistorySize"`
 	Tracer                            string        `json:"tracer"`
 	MaxProcessingTime                 time.Duration `json:"maxProcessingTime"`
 	CloseConsumerWhenThereIsNoMessage time.Duration `json:"closeConsumerWhenThereIsNoMessage"`
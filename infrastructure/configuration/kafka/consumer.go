@@ -2,8 +2,39 @@ package kafka
 
 import (
 	"context"
+	"errors"
 )
 
 type Consumer interface {
 	Consume(ctx context.Context, message *ConsumerMessage) error
 }
+
+type BatchConsumer interface {
+	ConsumeBatch(ctx context.Context, messages []*ConsumerMessage) error
+}
+
+type BatchError struct {
+	Errors map[*ConsumerMessage]error
+}
+
+func NewBatchError() *BatchError {
+	return &BatchError{
+		Errors: make(map[*ConsumerMessage]error),
+	}
+}
+
+func (e *BatchError) Add(message *ConsumerMessage, err error) {
+	e.Errors[message] = err
+}
+
+func (e *BatchError) HasErrors() bool {
+	return len(e.Errors) != 0
+}
+
+func (e *BatchError) Error() string {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...).Error()
+}
@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"presentation-advert-consumer/util"
+	"time"
+)
+
+type BatchConsumerMiddleware func(BatchConsumer) BatchConsumer
+
+type BatchConsumerFunc func(ctx context.Context, messages []*ConsumerMessage) error
+
+func (f BatchConsumerFunc) ConsumeBatch(ctx context.Context, messages []*ConsumerMessage) error {
+	return f(ctx, messages)
+}
+
+func DefaultBatchConsumerMiddlewares() []BatchConsumerMiddleware {
+	return []BatchConsumerMiddleware{
+		RecoveryBatchConsumerMiddleware,
+		CorrelationIdBatchConsumerMiddleware,
+		LoggingBatchConsumerMiddleware,
+		MetricsBatchConsumerMiddleware,
+	}
+}
+
+// chainBatchConsumerMiddlewares wraps the batch consumer so that the first middleware runs outermost.
+func chainBatchConsumerMiddlewares(consumer BatchConsumer, middlewares ...BatchConsumerMiddleware) BatchConsumer {
+	if consumer == nil {
+		return nil
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		consumer = middlewares[i](consumer)
+	}
+	return consumer
+}
+
+func LoggingBatchConsumerMiddleware(next BatchConsumer) BatchConsumer {
+	return BatchConsumerFunc(func(ctx context.Context, messages []*ConsumerMessage) error {
+		first, last := messages[0], messages[len(messages)-1]
+		log.Infof("Consumed batch, group: %s, topic: %s, partition: %d, offsets: %d-%d, size: %d",
+			first.GroupId, first.Topic, first.Partition, first.Offset, last.Offset, len(messages))
+		err := next.ConsumeBatch(ctx, messages)
+		if err != nil {
+			log.Errorf("An error occurred when consuming batch, group: %s, topic: %s, partition: %d, offsets: %d-%d, size: %d, err: %s",
+				first.GroupId, first.Topic, first.Partition, first.Offset, last.Offset, len(messages), err.Error())
+		}
+		return err
+	})
+}
+
+func MetricsBatchConsumerMiddleware(next BatchConsumer) BatchConsumer {
+	return BatchConsumerFunc(func(ctx context.Context, messages []*ConsumerMessage) error {
+		startTime := time.Now()
+		err := next.ConsumeBatch(ctx, messages)
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		consumerBatchDuration.WithLabelValues(messages[0].GroupId, messages[0].Topic, result).Observe(time.Since(startTime).Seconds())
+		consumerBatchSize.WithLabelValues(messages[0].GroupId, messages[0].Topic).Observe(float64(len(messages)))
+		return err
+	})
+}
+
+func RecoveryBatchConsumerMiddleware(next BatchConsumer) BatchConsumer {
+	return BatchConsumerFunc(func(ctx context.Context, messages []*ConsumerMessage) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				first := messages[0]
+				panicErr := recoverConsumerPanic(first.GroupId, first.Topic, r)
+				log.Errorf("Batch consumer panicked, topic: %s, partition: %d, offsets: %d-%d, err: %s, stack: %s", first.Topic, first.Partition, first.Offset, messages[len(messages)-1].Offset, panicErr.Error(), panicErr.Stack)
+				err = panicErr
+			}
+		}()
+		return next.ConsumeBatch(ctx, messages)
+	})
+}
+
+// CorrelationIdBatchConsumerMiddleware puts one correlation id for the batch into the context and adds it to the messages without one.
+func CorrelationIdBatchConsumerMiddleware(next BatchConsumer) BatchConsumer {
+	return BatchConsumerFunc(func(ctx context.Context, messages []*ConsumerMessage) error {
+		ctx = AddCorrelationIdToContextIfDoesNotExists(ctx)
+		correlationId := GetHeaderFromContext[string](ctx, CorrelationIdKey)
+		for _, message := range messages {
+			if getHeaderValue(message, CorrelationIdKey) == nil {
+				message.Headers = append(message.Headers, &sarama.RecordHeader{
+					Key:   util.ToByte(CorrelationIdKey.String()),
+					Value: util.ToByte(correlationId),
+				})
+			}
+		}
+		return next.ConsumeBatch(ctx, messages)
+	})
+}
@@ -1,9 +1,17 @@
 package kafka
 
+import (
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
+	"strings"
+)
+
 type consumerBuilder struct {
 	clusterConfigMap  ClusterConfigMap
 	consumerConfigMap ConsumerGroupConfigMap
 	consumersList     []*ConsumerGroupConsumers
+	middlewares       []ConsumerMiddleware
+	batchMiddlewares  []BatchConsumerMiddleware
+	errorClassifiers  map[string]ErrorClassifier
 }
 
 func NewConsumerBuilder(
@@ -15,22 +23,54 @@ func NewConsumerBuilder(
 		clusterConfigMap:  clusterConfigMap,
 		consumerConfigMap: consumerConfigMap,
 		consumersList:     consumersList,
+		errorClassifiers:  make(map[string]ErrorClassifier),
 	}
 }
 
+func (c *consumerBuilder) WithMiddlewares(middlewares ...ConsumerMiddleware) *consumerBuilder {
+	c.middlewares = append(c.middlewares, middlewares...)
+	return c
+}
+
+func (c *consumerBuilder) WithBatchMiddlewares(middlewares ...BatchConsumerMiddleware) *consumerBuilder {
+	c.batchMiddlewares = append(c.batchMiddlewares, middlewares...)
+	return c
+}
+
+// WithErrorClassifier replaces the default error classification of the consumer group config.
+func (c *consumerBuilder) WithErrorClassifier(configName string, classifier ErrorClassifier) *consumerBuilder {
+	c.errorClassifiers[strings.ToLower(configName)] = classifier
+	return c
+}
+
 func (c *consumerBuilder) Initialize() (map[string]ConsumerGroup, map[string]ErrorConsumerGroup, error) {
 	producers, err := NewProducerBuilder(c.clusterConfigMap).Initialize()
 	if err != nil {
 		return nil, nil, err
 	}
+	for configName := range c.errorClassifiers {
+		if _, exists := c.consumerConfigMap[configName]; !exists {
+			return nil, nil, custom_error.NewErrWithArgs("error classifier consumer config not found, config name: %s", configName)
+		}
+	}
 	clusterConsumerConfigConsumersMap := make(map[string]map[*ConsumerGroupConfig]*ConsumerGroupConsumers)
 	consumerGroupMap := make(map[string]ConsumerGroup)
 
-	for _, consumers := range c.consumersList {
+	for _, groupConsumers := range c.consumersList {
+		consumers := c.applyMiddlewares(groupConsumers)
 		consumerGroupConfig, err := c.consumerConfigMap.GetConfigWithDefault(consumers.ConfigName)
 		if err != nil {
 			return nil, nil, err
 		}
+		if classifier, exists := c.errorClassifiers[strings.ToLower(consumers.ConfigName)]; exists {
+			consumerGroupConfig.ErrorClassifier = classifier
+		}
+		if consumerGroupConfig.Batch && consumers.BatchConsumer == nil {
+			return nil, nil, custom_error.NewErrWithArgs("batch consumer required for batch mode, config name: %s", consumers.ConfigName)
+		}
+		if !consumerGroupConfig.Batch && consumers.Consumer == nil {
+			return nil, nil, custom_error.NewErrWithArgs("consumer required, config name: %s", consumers.ConfigName)
+		}
 		clusterName := consumerGroupConfig.Cluster
 		clusterConfig, err := c.clusterConfigMap.GetConfigWithDefault(clusterName)
 		if err != nil {
@@ -79,7 +119,7 @@ func (c *consumerBuilder) Initialize() (map[string]ConsumerGroup, map[string]Err
 			if consumers.ErrorConsumer != nil {
 				errorTopicConsumerMap[config.Error] = consumers.ErrorConsumer
 			} else {
-				errorTopicConsumerMap[config.Error] = NewDefaultErrorConsumer(producer)
+				errorTopicConsumerMap[config.Error] = chainConsumerMiddlewares(NewDefaultErrorConsumer(producer), c.middlewares...)
 			}
 			topics = append(topics, config.Error)
 		}
@@ -88,6 +128,9 @@ func (c *consumerBuilder) Initialize() (map[string]ConsumerGroup, map[string]Err
 			Topics:                            topics,
 			Cron:                              clusterConfig.ErrorConfig.Cron,
 			MaxErrorCount:                     clusterConfig.ErrorConfig.MaxErrorCount,
+			ParkingLotTopic:                   clusterConfig.ErrorConfig.ParkingLotTopic,
+			StatusTopic:                       clusterConfig.ErrorConfig.StatusTopic,
+			RunReportHistorySize:              clusterConfig.ErrorConfig.RunReportHistorySize,
 			Cluster:                           clusterName,
 			CloseConsumerWhenThereIsNoMessage: clusterConfig.ErrorConfig.CloseConsumerWhenThereIsNoMessage,
 			CloseConsumerWhenMessageIsNew:     clusterConfig.ErrorConfig.CloseConsumerWhenMessageIsNew,
@@ -98,7 +141,7 @@ func (c *consumerBuilder) Initialize() (map[string]ConsumerGroup, map[string]Err
 			RebalanceTimeout:                  clusterConfig.ErrorConfig.RebalanceTimeout,
 			HeartbeatInterval:                 clusterConfig.ErrorConfig.HeartbeatInterval,
 		}
-		errorConsumer, err := NewErrorConsumerGroup(clusterConfig, consumerGroupErrorConfig, errorTopicConsumerMap)
+		errorConsumer, err := NewErrorConsumerGroup(clusterConfig, consumerGroupErrorConfig, errorTopicConsumerMap, producer)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -109,3 +152,20 @@ func (c *consumerBuilder) Initialize() (map[string]ConsumerGroup, map[string]Err
 	}
 	return consumerGroupMap, errorConsumerGroupMap, nil
 }
+
+func (c *consumerBuilder) applyMiddlewares(consumers *ConsumerGroupConsumers) *ConsumerGroupConsumers {
+	middlewares := make([]ConsumerMiddleware, 0, len(c.middlewares)+len(consumers.Middlewares))
+	middlewares = append(middlewares, c.middlewares...)
+	middlewares = append(middlewares, consumers.Middlewares...)
+	batchMiddlewares := make([]BatchConsumerMiddleware, 0, len(c.batchMiddlewares)+len(consumers.BatchMiddlewares))
+	batchMiddlewares = append(batchMiddlewares, c.batchMiddlewares...)
+	batchMiddlewares = append(batchMiddlewares, consumers.BatchMiddlewares...)
+	return &ConsumerGroupConsumers{
+		ConfigName:       consumers.ConfigName,
+		Consumer:         chainConsumerMiddlewares(consumers.Consumer, middlewares...),
+		BatchConsumer:    chainBatchConsumerMiddlewares(consumers.BatchConsumer, batchMiddlewares...),
+		ErrorConsumer:    chainConsumerMiddlewares(consumers.ErrorConsumer, middlewares...),
+		Middlewares:      consumers.Middlewares,
+		BatchMiddlewares: consumers.BatchMiddlewares,
+	}
+}
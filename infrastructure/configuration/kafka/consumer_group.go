@@ -2,23 +2,35 @@ package kafka
 
 import (
 	"github.com/IBM/sarama"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"sync"
 )
 
 type ConsumerGroup interface {
 	GetGroupId() string
 	Subscribe() error
 	Unsubscribe() error
+	Pause()
+	Resume()
+	PausePartitions(topic string, partitions []int32)
+	IsPaused() bool
+	Snapshot() *ConsumerGroupState
 }
 
 type consumerGroup struct {
+	mutex         sync.RWMutex
 	clusterConfig *ClusterConfig
 	topicConfig   *ConsumerGroupConfig
+	status        ConsumerGroupStatus
 
 	// create in NewConsumerGroup
 	consumerGroupHandler consumerGroupHandler
+	pauser               *partitionPauser
+	transactions         *transactionalProducer
 	// create in Subscribe
 	client        Client
 	consumerGroup sarama.ConsumerGroup
+	lagReporter   *consumerGroupLagReporter
 }
 
 func NewConsumerGroup(
@@ -27,11 +39,19 @@ func NewConsumerGroup(
 	producer SyncProducer,
 	consumers *ConsumerGroupConsumers,
 ) (ConsumerGroup, error) {
-	consumerGroupHandler := newConsumerGroupHandlerImpl(consumerGroupConfig, consumers, producer)
+	var transactions *transactionalProducer
+	if consumerGroupConfig.CommitMode == CommitModeExactlyOnce {
+		transactions = newTransactionalProducer(clusterConfig, consumerGroupConfig)
+	}
+	pauser := newPartitionPauser()
+	consumerGroupHandler := newConsumerGroupHandlerImpl(consumerGroupConfig, consumers, producer, transactions, pauser)
 	return &consumerGroup{
 		clusterConfig:        clusterConfig,
 		topicConfig:          consumerGroupConfig,
 		consumerGroupHandler: consumerGroupHandler,
+		pauser:               pauser,
+		transactions:         transactions,
+		status:               ConsumerGroupCreated,
 	}, nil
 }
 
@@ -40,24 +60,80 @@ func (c *consumerGroup) GetGroupId() string {
 }
 
 func (c *consumerGroup) Subscribe() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	saramaConfig, err := getSaramaConfig(c.clusterConfig, c.topicConfig)
 	if err != nil {
 		return err
 	}
+	if c.transactions != nil {
+		if err := c.transactions.open(); err != nil {
+			return err
+		}
+	}
 	client, cg, err := subscribe(saramaConfig, c.clusterConfig, c.consumerGroupHandler, c.topicConfig.GroupId, c.topicConfig.GetTopics(), false)
 	if err != nil {
 		return err
 	}
 	c.client = client
 	c.consumerGroup = cg
+	c.pauser.setConsumerGroup(cg)
+	c.lagReporter = newConsumerGroupLagReporter(c.topicConfig.GroupId, client, c.consumerGroupHandler, c.topicConfig.LagReportInterval)
+	c.lagReporter.start()
+	c.status = ConsumerGroupSubscribed
 	return nil
 }
 
 func (c *consumerGroup) Unsubscribe() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.lagReporter != nil {
+		c.lagReporter.close()
+		c.lagReporter = nil
+	}
 	if err := unsubscribe(c.client, c.consumerGroup); err != nil {
 		return err
 	}
+	c.pauser.setConsumerGroup(nil)
 	c.client = nil
 	c.consumerGroup = nil
+	if c.transactions != nil {
+		if err := c.transactions.Close(); err != nil {
+			log.Errorf("Transactional producer could not be closed, groupId: %s, err: %s", c.topicConfig.GroupId, err.Error())
+		}
+	}
+	c.status = ConsumerGroupUnsubscribed
 	return nil
 }
+
+func (c *consumerGroup) Pause() {
+	log.Infof("consumerGroup Pause, groupId: %s", c.topicConfig.GroupId)
+	c.pauser.pauseAll()
+}
+
+func (c *consumerGroup) Resume() {
+	log.Infof("consumerGroup Resume, groupId: %s", c.topicConfig.GroupId)
+	c.pauser.resumeAll()
+}
+
+func (c *consumerGroup) PausePartitions(topic string, partitions []int32) {
+	log.Infof("consumerGroup PausePartitions, groupId: %s, topic: %s, partitions: %v", c.topicConfig.GroupId, topic, partitions)
+	c.pauser.pause(topic, partitions)
+}
+
+func (c *consumerGroup) IsPaused() bool {
+	return c.pauser.isPaused()
+}
+
+func (c *consumerGroup) Snapshot() *ConsumerGroupState {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	config := *c.topicConfig
+	return &ConsumerGroupState{
+		GroupId:                   c.topicConfig.GroupId,
+		Config:                    &config,
+		Status:                    c.status,
+		Paused:                    c.pauser.isPaused(),
+		ConsumerGroupHandlerState: c.consumerGroupHandler.Snapshot(),
+	}
+}
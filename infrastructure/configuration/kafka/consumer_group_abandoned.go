@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"fmt"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"sync"
+)
+
+// abandonedHandlerTracker counts handlers still running after MaxProcessingTime across the group and holds the partitions with stuck handlers while the group exceeds the limit.
+type abandonedHandlerTracker struct {
+	mutex          sync.Mutex
+	groupId        string
+	limit          int
+	timeoutPolicy  TimeoutPolicy
+	pauser         *partitionPauser
+	total          int
+	counts         map[string]int
+	heldPartitions map[string]map[int32]struct{}
+}
+
+func newAbandonedHandlerTracker(groupId string, limit int, timeoutPolicy TimeoutPolicy, pauser *partitionPauser) *abandonedHandlerTracker {
+	return &abandonedHandlerTracker{
+		groupId:        groupId,
+		limit:          limit,
+		timeoutPolicy:  timeoutPolicy,
+		pauser:         pauser,
+		counts:         make(map[string]int),
+		heldPartitions: make(map[string]map[int32]struct{}),
+	}
+}
+
+func (tracker *abandonedHandlerTracker) abandon(topic string, partition int32) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	key := getTopicPartitionKey(topic, partition)
+	tracker.total++
+	tracker.counts[key]++
+	count := tracker.counts[key]
+	consumerStuckHandlers.WithLabelValues(tracker.groupId, topic, fmt.Sprint(partition)).Set(float64(count))
+	log.Errorf("Handler exceeded max processing time and is still running, group: %s, topic: %s, partition: %d, stuck handlers: %d, group stuck handlers: %d", tracker.groupId, topic, partition, count, tracker.total)
+	if _, held := tracker.heldPartitions[topic][partition]; !held && tracker.total > tracker.limit {
+		log.Errorf("Group stuck handlers exceeded the limit, pausing partition, group: %s, topic: %s, partition: %d, limit: %d", tracker.groupId, topic, partition, tracker.limit)
+		if _, exists := tracker.heldPartitions[topic]; !exists {
+			tracker.heldPartitions[topic] = make(map[int32]struct{})
+		}
+		tracker.heldPartitions[topic][partition] = struct{}{}
+		tracker.pauser.hold(topic, partition)
+	}
+}
+
+func (tracker *abandonedHandlerTracker) release(topic string, partition int32) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	key := getTopicPartitionKey(topic, partition)
+	tracker.total--
+	tracker.counts[key]--
+	count := tracker.counts[key]
+	if count <= 0 {
+		delete(tracker.counts, key)
+		consumerStuckHandlers.DeleteLabelValues(tracker.groupId, topic, fmt.Sprint(partition))
+	} else {
+		consumerStuckHandlers.WithLabelValues(tracker.groupId, topic, fmt.Sprint(partition)).Set(float64(count))
+	}
+	if tracker.total > tracker.limit || len(tracker.heldPartitions) == 0 {
+		return
+	}
+	log.Infof("Group stuck handlers are back under the limit, resuming held partitions, group: %s, limit: %d", tracker.groupId, tracker.limit)
+	for heldTopic, heldPartitions := range tracker.heldPartitions {
+		for heldPartition := range heldPartitions {
+			tracker.pauser.unhold(heldTopic, heldPartition)
+		}
+	}
+	tracker.heldPartitions = make(map[string]map[int32]struct{})
+}
+
+func (tracker *abandonedHandlerTracker) blocks() bool {
+	return tracker.timeoutPolicy == TimeoutPolicyBlock
+}
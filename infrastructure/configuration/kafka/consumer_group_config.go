@@ -1,44 +1,143 @@
 package kafka
 
 import (
+	"context"
+	"errors"
 	"github.com/IBM/sarama"
 	"github.com/docker/go-units"
+	"math"
+	"math/rand"
 	"presentation-advert-consumer/infrastructure/configuration/custom_error"
 	"strings"
 	"time"
 )
 
+// maxBatchSize keeps a batch within one bulk request of the stores the batch consumers write to.
+const maxBatchSize = 1000
+
 type ConsumerGroupConfig struct {
-	GroupId              string        `json:"groupId"`
-	Name                 string        `json:"name"`
-	Retry                string        `json:"retry"`
-	Error                string        `json:"error"`
-	RetryCount           int           `json:"retryCount"`
-	Cluster              string        `json:"cluster"`
-	MaxProcessingTime    time.Duration `json:"maxProcessingTime"`
-	FetchMaxBytes        int32         `json:"fetchMaxBytes"`
-	DisableErrorConsumer bool          `json:"disableErrorConsumer"`
-	OffsetInitial        OffsetInitial `json:"offsetInitial"`
-	SessionTimeout       time.Duration `json:"sessionTimeout"`
-	RebalanceTimeout     time.Duration `json:"rebalanceTimeout"`
-	HeartbeatInterval    time.Duration `json:"heartbeatInterval"`
+	GroupId              string            `json:"groupId"`
+	Name                 string            `json:"name"`
+	Retry                string            `json:"retry"`
+	Error                string            `json:"error"`
+	RetryCount           int               `json:"retryCount"`
+	Cluster              string            `json:"cluster"`
+	MaxProcessingTime    time.Duration     `json:"maxProcessingTime"`
+	FetchMaxBytes        int32             `json:"fetchMaxBytes"`
+	DisableErrorConsumer bool              `json:"disableErrorConsumer"`
+	OffsetInitial        OffsetInitial     `json:"offsetInitial"`
+	SessionTimeout       time.Duration     `json:"sessionTimeout"`
+	RebalanceTimeout     time.Duration     `json:"rebalanceTimeout"`
+	HeartbeatInterval    time.Duration     `json:"heartbeatInterval"`
+	Concurrency          int               `json:"concurrency"`
+	Batch                bool              `json:"batch"`
+	BatchSize            int               `json:"batchSize"`
+	BatchMaxWait         time.Duration     `json:"batchMaxWait"`
+	CommitMode           CommitMode        `json:"commitMode"`
+	IsolationLevel       IsolationLevel    `json:"isolationLevel"`
+	HandOffBackoff       time.Duration     `json:"handOffBackoff"`
+	HandOffMaxBackoff    time.Duration     `json:"handOffMaxBackoff"`
+	LagReportInterval    time.Duration     `json:"lagReportInterval"`
+	EventTypes           map[string]string `json:"eventTypes"`
+	MaxAbandonedHandlers int               `json:"maxAbandonedHandlers"`
+	TimeoutPolicy        TimeoutPolicy     `json:"timeoutPolicy"`
+	RetryDelay           time.Duration     `json:"retryDelay"`
+	RetryDelayMultiplier float64           `json:"retryDelayMultiplier"`
+	RetryMaxDelay        time.Duration     `json:"retryMaxDelay"`
+	RetryTiers           []*RetryTier      `json:"retryTiers"`
+	OrderedRetry         bool              `json:"orderedRetry"`
+	OrderedRetryMaxHold  time.Duration     `json:"orderedRetryMaxHold"`
+	LocalRetry           *LocalRetryPolicy `json:"localRetry"`
+	ErrorClassifier      ErrorClassifier   `json:"-" mapstructure:"-"`
+}
+
+type ErrorClassifier func(err error) custom_error.ErrorClass
+
+type RetryTier struct {
+	Topic           string        `json:"topic"`
+	Delay           time.Duration `json:"delay"`
+	DelayMultiplier float64       `json:"delayMultiplier"`
+	MaxDelay        time.Duration `json:"maxDelay"`
+	Attempts        int           `json:"attempts"`
+}
+
+func (t *RetryTier) GetDelay(retriedCount int) time.Duration {
+	if t.Delay <= 0 {
+		return 0
+	}
+	delay := float64(t.Delay) * math.Pow(t.DelayMultiplier, float64(retriedCount))
+	if delay > float64(t.MaxDelay) {
+		return t.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+type LocalRetryPolicy struct {
+	Attempts   int                  `json:"attempts"`
+	Backoff    time.Duration        `json:"backoff"`
+	MaxBackoff time.Duration        `json:"maxBackoff"`
+	Jitter     float64              `json:"jitter"`
+	Retryable  func(err error) bool `json:"-" mapstructure:"-"`
+}
+
+func (p *LocalRetryPolicy) GetBackoff(attempt int) time.Duration {
+	backoff := min(float64(p.Backoff)*math.Pow(2, float64(attempt-1)), float64(p.MaxBackoff))
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// IsRetryable falls back to the error classifier of the consumer group config when the policy has no Retryable func.
+func (p *LocalRetryPolicy) IsRetryable(err error, classify ErrorClassifier) bool {
+	var panicErr *PanicError
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &panicErr) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	if classify != nil {
+		return classify(err) == custom_error.ErrorClassRetryable
+	}
+	return custom_error.IsRetryable(err)
+}
+
+func (p *LocalRetryPolicy) shouldRetry(attempt int, err error, classify ErrorClassifier) bool {
+	return p != nil && attempt < p.Attempts && p.IsRetryable(err, classify)
 }
 
 func (c *ConsumerGroupConfig) GetTopics() map[string]struct{} {
 	topics := make(map[string]struct{})
 	topics[c.Name] = struct{}{}
-	if len(c.Retry) != 0 {
-		topics[c.Retry] = struct{}{}
+	for _, tier := range c.RetryTiers {
+		topics[tier.Topic] = struct{}{}
 	}
 	return topics
 }
 
+func (c *ConsumerGroupConfig) ClassifyError(err error) custom_error.ErrorClass {
+	if c.ErrorClassifier != nil {
+		return c.ErrorClassifier(err)
+	}
+	return custom_error.Classify(err)
+}
+
+func (c *ConsumerGroupConfig) GetRetryTierIndex(topic string) int {
+	for i, tier := range c.RetryTiers {
+		if tier.Topic == topic {
+			return i
+		}
+	}
+	return -1
+}
+
 func (c *ConsumerGroupConfig) IsNotDefinedRetryAndErrorTopic() bool {
 	return c.IsNotDefinedRetryTopic() && c.IsNotDefinedErrorTopic()
 }
 
 func (c *ConsumerGroupConfig) IsNotDefinedRetryTopic() bool {
-	return len(c.Retry) == 0
+	return len(c.RetryTiers) == 0
 }
 
 func (c *ConsumerGroupConfig) IsNotDefinedErrorTopic() bool {
@@ -87,11 +186,224 @@ func (c ConsumerGroupConfigMap) GetConfigWithDefault(name string) (*ConsumerGrou
 		if config.HeartbeatInterval == 0 {
 			config.HeartbeatInterval = 3 * time.Second
 		}
+		if config.Concurrency < 0 {
+			return nil, custom_error.NewErrWithArgs("consumer topic config concurrency must be positive, config name: %s", name)
+		}
+		if config.Concurrency == 0 {
+			config.Concurrency = 1
+		}
+		if config.Batch && config.Concurrency > 1 {
+			return nil, custom_error.NewErrWithArgs("consumer topic config batch mode does not support concurrency, config name: %s", name)
+		}
+		if config.BatchSize < 0 {
+			return nil, custom_error.NewErrWithArgs("consumer topic config batch size must be positive, config name: %s", name)
+		}
+		if config.BatchSize > maxBatchSize {
+			return nil, custom_error.NewErrWithArgs("consumer topic config batch size must not be greater than %d, config name: %s", maxBatchSize, name)
+		}
+		if config.BatchSize == 0 {
+			config.BatchSize = 100
+		}
+		if config.BatchMaxWait == 0 {
+			config.BatchMaxWait = 1 * time.Second
+		}
+		if len(config.CommitMode) == 0 {
+			config.CommitMode = CommitModeAuto
+		}
+		if config.CommitMode != CommitModeAuto && config.CommitMode != CommitModeAtLeastOnce && config.CommitMode != CommitModeExactlyOnce {
+			return nil, custom_error.NewErrWithArgs("consumer topic config commit mode should be auto, atLeastOnce or exactlyOnce, config name: %s", name)
+		}
+		if config.CommitMode == CommitModeExactlyOnce && config.Concurrency > 1 {
+			return nil, custom_error.NewErrWithArgs("consumer topic config exactly once commit mode does not support concurrency, config name: %s", name)
+		}
+		if len(config.IsolationLevel) == 0 && config.CommitMode == CommitModeExactlyOnce {
+			config.IsolationLevel = ReadCommitted
+		}
+		if len(config.IsolationLevel) == 0 {
+			config.IsolationLevel = ReadUncommitted
+		}
+		if config.IsolationLevel != ReadUncommitted && config.IsolationLevel != ReadCommitted {
+			return nil, custom_error.NewErrWithArgs("consumer topic config isolation level should be readUncommitted or readCommitted, config name: %s", name)
+		}
+		if config.HandOffBackoff == 0 {
+			config.HandOffBackoff = 1 * time.Second
+		}
+		if config.HandOffMaxBackoff == 0 {
+			config.HandOffMaxBackoff = 1 * time.Minute
+		}
+		if config.LagReportInterval == 0 {
+			config.LagReportInterval = 30 * time.Second
+		}
+		if config.MaxAbandonedHandlers < 0 {
+			return nil, custom_error.NewErrWithArgs("consumer topic config max abandoned handlers must be positive, config name: %s", name)
+		}
+		if config.MaxAbandonedHandlers == 0 {
+			config.MaxAbandonedHandlers = 10
+		}
+		if len(config.TimeoutPolicy) == 0 {
+			config.TimeoutPolicy = TimeoutPolicyRetry
+		}
+		if config.TimeoutPolicy != TimeoutPolicyRetry && config.TimeoutPolicy != TimeoutPolicyError && config.TimeoutPolicy != TimeoutPolicyBlock {
+			return nil, custom_error.NewErrWithArgs("consumer topic config timeout policy should be retry, error or block, config name: %s", name)
+		}
+		if config.RetryDelay < 0 {
+			return nil, custom_error.NewErrWithArgs("consumer topic config retry delay must be positive, config name: %s", name)
+		}
+		if config.RetryDelayMultiplier == 0 {
+			config.RetryDelayMultiplier = 2
+		}
+		if config.RetryDelayMultiplier < 1 {
+			return nil, custom_error.NewErrWithArgs("consumer topic config retry delay multiplier must be at least 1, config name: %s", name)
+		}
+		if config.RetryMaxDelay == 0 {
+			config.RetryMaxDelay = max(config.RetryDelay, 5*time.Minute)
+		}
+		if config.RetryMaxDelay < config.RetryDelay {
+			return nil, custom_error.NewErrWithArgs("consumer topic config retry max delay must not be less than retry delay, config name: %s", name)
+		}
+		if len(config.RetryTiers) == 0 && len(config.Retry) > 0 {
+			config.RetryTiers = []*RetryTier{{
+				Topic:           config.Retry,
+				Delay:           config.RetryDelay,
+				DelayMultiplier: config.RetryDelayMultiplier,
+				MaxDelay:        config.RetryMaxDelay,
+				Attempts:        config.RetryCount,
+			}}
+		}
+		if err := validateRetryTiers(config, name); err != nil {
+			return nil, err
+		}
+		if config.OrderedRetry && len(config.RetryTiers) == 0 {
+			return nil, custom_error.NewErrWithArgs("consumer topic config ordered retry requires a retry topic, config name: %s", name)
+		}
+		for _, tier := range config.RetryTiers {
+			if config.OrderedRetry && tier.Delay == 0 {
+				return nil, custom_error.NewErrWithArgs("consumer topic config ordered retry requires a retry tier delay, config name: %s, topic: %s", name, tier.Topic)
+			}
+		}
+		if config.OrderedRetryMaxHold < 0 {
+			return nil, custom_error.NewErrWithArgs("consumer topic config ordered retry max hold must be positive, config name: %s", name)
+		}
+		if config.OrderedRetryMaxHold == 0 {
+			config.OrderedRetryMaxHold = 1 * time.Hour
+		}
+		if err := validateLocalRetry(config, name); err != nil {
+			return nil, err
+		}
 		return config, nil
 	}
 	return nil, custom_error.NewErrWithArgs("config not found: %s", name)
 }
 
+func validateRetryTiers(config *ConsumerGroupConfig, name string) error {
+	if len(config.RetryTiers) == 0 {
+		return nil
+	}
+	if len(config.Retry) > 0 && config.Retry != config.RetryTiers[0].Topic {
+		return custom_error.NewErrWithArgs("consumer topic config retry topic should be the first retry tier, config name: %s", name)
+	}
+	topics := map[string]struct{}{config.Name: {}}
+	if len(config.Error) > 0 {
+		topics[config.Error] = struct{}{}
+	}
+	for i, tier := range config.RetryTiers {
+		if tier == nil || len(tier.Topic) == 0 {
+			return custom_error.NewErrWithArgs("consumer topic config retry tier topic required, config name: %s, tier: %d", name, i)
+		}
+		if _, exists := topics[tier.Topic]; exists {
+			return custom_error.NewErrWithArgs("consumer topic config retry tier topic is duplicated, config name: %s, topic: %s", name, tier.Topic)
+		}
+		topics[tier.Topic] = struct{}{}
+		if tier.Attempts < 0 || tier.Delay < 0 || tier.DelayMultiplier < 0 || tier.MaxDelay < 0 {
+			return custom_error.NewErrWithArgs("consumer topic config retry tier values must be positive, config name: %s, topic: %s", name, tier.Topic)
+		}
+		if tier.Attempts == 0 {
+			tier.Attempts = 1
+		}
+		if tier.DelayMultiplier == 0 {
+			tier.DelayMultiplier = 1
+		}
+		if tier.DelayMultiplier < 1 {
+			return custom_error.NewErrWithArgs("consumer topic config retry tier delay multiplier must be at least 1, config name: %s, topic: %s", name, tier.Topic)
+		}
+		if tier.MaxDelay == 0 {
+			tier.MaxDelay = tier.Delay
+		}
+		if tier.MaxDelay < tier.Delay {
+			return custom_error.NewErrWithArgs("consumer topic config retry tier max delay must not be less than delay, config name: %s, topic: %s", name, tier.Topic)
+		}
+	}
+	config.Retry = config.RetryTiers[0].Topic
+	return nil
+}
+
+func validateLocalRetry(config *ConsumerGroupConfig, name string) error {
+	policy := config.LocalRetry
+	if policy == nil {
+		return nil
+	}
+	if config.Batch {
+		return custom_error.NewErrWithArgs("consumer topic config local retry is not supported in batch mode, config name: %s", name)
+	}
+	if policy.Attempts < 0 || policy.Backoff < 0 || policy.MaxBackoff < 0 {
+		return custom_error.NewErrWithArgs("consumer topic config local retry values must be positive, config name: %s", name)
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return custom_error.NewErrWithArgs("consumer topic config local retry jitter should be between 0 and 1, config name: %s", name)
+	}
+	if policy.Attempts == 0 {
+		policy.Attempts = 3
+	}
+	if policy.Backoff == 0 {
+		policy.Backoff = 100 * time.Millisecond
+	}
+	if policy.MaxBackoff == 0 {
+		policy.MaxBackoff = max(policy.Backoff, 1*time.Second)
+	}
+	if policy.MaxBackoff < policy.Backoff {
+		return custom_error.NewErrWithArgs("consumer topic config local retry max backoff must not be less than backoff, config name: %s", name)
+	}
+	if policy.MaxBackoff >= config.MaxProcessingTime {
+		return custom_error.NewErrWithArgs("consumer topic config local retry max backoff must be less than max processing time, config name: %s", name)
+	}
+	return nil
+}
+
+type CommitMode string
+
+const (
+	CommitModeAuto        CommitMode = "auto"
+	CommitModeAtLeastOnce CommitMode = "atLeastOnce"
+	CommitModeExactlyOnce CommitMode = "exactlyOnce"
+)
+
+// BlocksOnHandOffError reports whether a message that could not be handed off must hold its partition instead of being marked.
+func (m CommitMode) BlocksOnHandOffError() bool {
+	return m == CommitModeAtLeastOnce || m == CommitModeExactlyOnce
+}
+
+type IsolationLevel string
+
+const (
+	ReadUncommitted IsolationLevel = "readUncommitted"
+	ReadCommitted   IsolationLevel = "readCommitted"
+)
+
+func (i IsolationLevel) GetSaramaIsolationLevel() sarama.IsolationLevel {
+	if i == ReadCommitted {
+		return sarama.ReadCommitted
+	}
+	return sarama.ReadUncommitted
+}
+
+type TimeoutPolicy string
+
+const (
+	TimeoutPolicyRetry TimeoutPolicy = "retry"
+	TimeoutPolicyError TimeoutPolicy = "error"
+	TimeoutPolicyBlock TimeoutPolicy = "block"
+)
+
 type OffsetInitial string
 
 const (
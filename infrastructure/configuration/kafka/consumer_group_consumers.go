@@ -1,9 +1,12 @@
 package kafka
 
 type ConsumerGroupConsumers struct {
-	ConfigName    string
-	Consumer      Consumer
-	ErrorConsumer Consumer
+	ConfigName       string
+	Consumer         Consumer
+	BatchConsumer    BatchConsumer
+	ErrorConsumer    Consumer
+	Middlewares      []ConsumerMiddleware
+	BatchMiddlewares []BatchConsumerMiddleware
 }
 
 type ConsumerGroupErrorConsumers struct {
@@ -1,9 +1,12 @@
 package kafka
 
 import (
+	"context"
 	"github.com/IBM/sarama"
 	"github.com/robfig/cron/v3"
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
 	"presentation-advert-consumer/infrastructure/configuration/log"
+	"sync"
 	"time"
 )
 
@@ -11,36 +14,56 @@ type ErrorConsumerGroup interface {
 	ScheduleToSubscribe() error
 	GetGroupId() string
 	Subscribe()
+	TriggerRun() error
+	GetRunReports() []*ErrorConsumerRunReport
 	Unsubscribe() error
 	IsSubscribed() bool
+	Snapshot() *ConsumerGroupState
+	ListErrorMessages(ctx context.Context, filter *ErrorMessageFilter) (*ErrorMessageList, error)
+	ReplayErrorMessages(ctx context.Context, coordinates []*ErrorMessageCoordinate) (*ErrorReplayResult, error)
+	SkipErrorMessages(coordinates []*ErrorMessageCoordinate) error
+	ReplayMatchingErrorMessages(ctx context.Context, filter *ErrorMessageFilter, dryRun bool) (*ErrorReplayResult, error)
 }
 
 type errorConsumerGroup struct {
+	mutex                                sync.RWMutex
 	client                               Client
 	clusterConfig2                       *ClusterConfig
 	consumerGroup                        sarama.ConsumerGroup
 	errorConsumerGroupHandler            consumerGroupHandler
 	consumerGroupErrorConfig             *ConsumerGroupErrorConfig
 	errorTopicConsumerMap                map[string]Consumer
+	producer                             SyncProducer
+	registry                             *errorMessageRegistry
+	browser                              *errorTopicBrowser
+	runRecorder                          *errorConsumerRunRecorder
 	scheduleToSubscribeCron              *cron.Cron
 	checkConsumerGroupHandlerStateTicker *time.Ticker
-	state                                *ConsumerGroupState
+	status                               ConsumerGroupStatus
 }
 
 func NewErrorConsumerGroup(
 	clusterConfig *ClusterConfig,
 	consumerGroupErrorConfig *ConsumerGroupErrorConfig,
 	errorTopicConsumerMap map[string]Consumer,
+	producer SyncProducer,
 ) (ErrorConsumerGroup, error) {
+	var store *errorMessageStatusStore
+	if consumerGroupErrorConfig.IsDefinedStatusTopic() {
+		store = newErrorMessageStatusStore(clusterConfig, consumerGroupErrorConfig, producer)
+	}
+	registry := newErrorMessageRegistry(store)
 	errorConsumerGroup := &errorConsumerGroup{
 		clusterConfig2:                       clusterConfig,
 		consumerGroupErrorConfig:             consumerGroupErrorConfig,
 		errorTopicConsumerMap:                errorTopicConsumerMap,
+		producer:                             producer,
+		registry:                             registry,
+		browser:                              newErrorTopicBrowser(clusterConfig, consumerGroupErrorConfig, producer, registry),
+		runRecorder:                          newErrorConsumerRunRecorder(consumerGroupErrorConfig.GroupId, consumerGroupErrorConfig.RunReportHistorySize),
 		scheduleToSubscribeCron:              cron.New(),
 		checkConsumerGroupHandlerStateTicker: time.NewTicker(2 * time.Second),
-		state: &ConsumerGroupState{
-			Status: ConsumerGroupCreated,
-		},
+		status:                               ConsumerGroupCreated,
 	}
 	go func() {
 		errorConsumerGroup.listenConsumerStatus()
@@ -57,13 +80,14 @@ func (c *errorConsumerGroup) ScheduleToSubscribe() error {
 }
 
 func (c *errorConsumerGroup) IsStarted() bool {
-	if !c.IsSubscribed() {
+	snapshot := c.Snapshot()
+	if snapshot.Status != ConsumerGroupSubscribed || snapshot.ConsumerGroupHandlerState == nil {
 		return false
 	}
-	if c.state.ConsumerGroupHandlerState.Status != ConsumerGroupHandlerStarted {
+	if snapshot.ConsumerGroupHandlerState.Status != ConsumerGroupHandlerStarted {
 		return false
 	}
-	for _, state := range c.state.ConsumerGroupHandlerState.ConsumerTopicStates {
+	for _, state := range snapshot.ConsumerGroupHandlerState.ConsumerTopicStates {
 		if state.Status != ConsumerGroupHandlerTopicListening && state.Status != ConsumerGroupHandlerTopicStarted {
 			return false
 		}
@@ -76,38 +100,60 @@ func (c *errorConsumerGroup) GetGroupId() string {
 }
 
 func (c *errorConsumerGroup) Subscribe() {
-	if c.IsSubscribed() {
+	_ = c.subscribe(ErrorConsumerRunCron)
+}
+
+func (c *errorConsumerGroup) TriggerRun() error {
+	return c.subscribe(ErrorConsumerRunManual)
+}
+
+func (c *errorConsumerGroup) GetRunReports() []*ErrorConsumerRunReport {
+	return c.runRecorder.Reports()
+}
+
+func (c *errorConsumerGroup) subscribe(trigger ErrorConsumerRunTrigger) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.status == ConsumerGroupSubscribed {
 		log.Infof("errorConsumerGroup is already running, groupId: %s", c.consumerGroupErrorConfig.GroupId)
-		return
+		return custom_error.ConflictErrWithArgs("error consumer group is already running, groupId: %s", c.consumerGroupErrorConfig.GroupId)
 	}
 	topics := c.getErrorTopics()
 	if len(topics) == 0 {
-		return
+		return custom_error.BadRequestErrWithArgs("error consumer group has no error topic, groupId: %s", c.consumerGroupErrorConfig.GroupId)
 	}
-	log.Infof("errorConsumerGroup Subscribe, groupId: %s", c.consumerGroupErrorConfig.GroupId)
+	log.Infof("errorConsumerGroup Subscribe, groupId: %s, trigger: %s", c.consumerGroupErrorConfig.GroupId, trigger)
 	saramaConfig, err := getSaramaErrorConfig(c.clusterConfig2, c.consumerGroupErrorConfig)
 	if err != nil {
 		log.Errorf("errorConsumerGroup Subscribe err: %s", err.Error())
-		return
+		return err
+	}
+	if err := c.registry.refresh(context.Background()); err != nil {
+		log.Errorf("errorConsumerGroup Subscribe could not load error message statuses, groupId: %s, err: %s", c.consumerGroupErrorConfig.GroupId, err.Error())
+		return err
 	}
-	handler := newErrorConsumerGroupHandler(c.consumerGroupErrorConfig, c.errorTopicConsumerMap)
+	handler := newErrorConsumerGroupHandler(c.consumerGroupErrorConfig, c.errorTopicConsumerMap, c.producer, c.registry, c.runRecorder)
 	c.errorConsumerGroupHandler = handler
-	c.state.ConsumerGroupHandlerState = handler.GetState()
 
+	c.runRecorder.start(trigger)
 	client, cg, err := subscribe(saramaConfig, c.clusterConfig2, c.errorConsumerGroupHandler, c.consumerGroupErrorConfig.GroupId, topics, true)
 	if err != nil {
 		log.Errorf("errorConsumerGroup Subscribe err: %s", err.Error())
-		return
+		c.runRecorder.finish()
+		return err
 	}
 	c.client = client
 	c.consumerGroup = cg
-	c.state.Status = ConsumerGroupSubscribed
+	c.status = ConsumerGroupSubscribed
+	return nil
 }
 
 func (c *errorConsumerGroup) Unsubscribe() error {
 	if !c.existsErrorTopic() {
 		return nil
 	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	log.Infof("errorConsumerGroup Unsubscribe, groupId: %s", c.consumerGroupErrorConfig.GroupId)
 	if err := unsubscribe(c.client, c.consumerGroup); err != nil {
 		log.Errorf("errorConsumerGroup Unsubscribe err: %s", err.Error())
@@ -115,7 +161,10 @@ func (c *errorConsumerGroup) Unsubscribe() error {
 	}
 	c.client = nil
 	c.consumerGroup = nil
-	c.state.Status = ConsumerGroupUnsubscribed
+	if c.status == ConsumerGroupSubscribed {
+		c.runRecorder.finish()
+	}
+	c.status = ConsumerGroupUnsubscribed
 	return nil
 }
 
@@ -123,23 +172,25 @@ func (c *errorConsumerGroup) listenConsumerStatus() {
 	closeConsumerWhenThereIsNoMessage := c.consumerGroupErrorConfig.CloseConsumerWhenThereIsNoMessage.Nanoseconds()
 	closeConsumerWhenMessageIsNew := c.consumerGroupErrorConfig.CloseConsumerWhenMessageIsNew.Nanoseconds()
 	for range c.checkConsumerGroupHandlerStateTicker.C {
-		if c.state.Status != ConsumerGroupSubscribed {
+		snapshot := c.Snapshot()
+		if snapshot.Status != ConsumerGroupSubscribed {
 			continue
 		}
-		if c.state.ConsumerGroupHandlerState == nil {
+		handlerState := snapshot.ConsumerGroupHandlerState
+		if handlerState == nil {
 			continue
 		}
-		if c.state.ConsumerGroupHandlerState.Status == ConsumerGroupHandlerClosed {
+		if handlerState.Status == ConsumerGroupHandlerClosed {
 			_ = c.Unsubscribe()
 			continue
 		}
 		allTopicStateAreUnsubscribable := false
-		for _, state := range c.state.ConsumerGroupHandlerState.ConsumerTopicStates {
+		for _, state := range handlerState.ConsumerTopicStates {
 			if state.Status == ConsumerGroupHandlerTopicNewMessage ||
 				(state.Status == ConsumerGroupHandlerTopicCreated && time.Since(state.CreatedDate).Nanoseconds() > closeConsumerWhenThereIsNoMessage) ||
-				(state.Status == ConsumerGroupHandlerTopicListening && time.Since(state.ListeningDate).Nanoseconds() > closeConsumerWhenThereIsNoMessage) ||
-				(state.Status == ConsumerGroupHandlerTopicStarted && time.Since(state.LatestConsumedDate).Nanoseconds() > closeConsumerWhenThereIsNoMessage) ||
-				(state.Status == ConsumerGroupHandlerTopicStarted && time.Since(state.LatestConsumedDate).Nanoseconds() > closeConsumerWhenMessageIsNew) {
+				(state.Status == ConsumerGroupHandlerTopicListening && time.Since(*state.ListeningDate).Nanoseconds() > closeConsumerWhenThereIsNoMessage) ||
+				(state.Status == ConsumerGroupHandlerTopicStarted && time.Since(*state.LatestConsumedDate).Nanoseconds() > closeConsumerWhenThereIsNoMessage) ||
+				(state.Status == ConsumerGroupHandlerTopicStarted && time.Since(*state.LatestConsumedDate).Nanoseconds() > closeConsumerWhenMessageIsNew) {
 				allTopicStateAreUnsubscribable = true
 			} else {
 				allTopicStateAreUnsubscribable = false
@@ -153,7 +204,40 @@ func (c *errorConsumerGroup) listenConsumerStatus() {
 }
 
 func (c *errorConsumerGroup) IsSubscribed() bool {
-	return c.state.Status == ConsumerGroupSubscribed
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.status == ConsumerGroupSubscribed
+}
+
+func (c *errorConsumerGroup) Snapshot() *ConsumerGroupState {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	config := *c.consumerGroupErrorConfig
+	snapshot := &ConsumerGroupState{
+		GroupId:     c.consumerGroupErrorConfig.GroupId,
+		ErrorConfig: &config,
+		Status:      c.status,
+	}
+	if c.errorConsumerGroupHandler != nil {
+		snapshot.ConsumerGroupHandlerState = c.errorConsumerGroupHandler.Snapshot()
+	}
+	return snapshot
+}
+
+func (c *errorConsumerGroup) ListErrorMessages(ctx context.Context, filter *ErrorMessageFilter) (*ErrorMessageList, error) {
+	return c.browser.list(ctx, filter)
+}
+
+func (c *errorConsumerGroup) ReplayErrorMessages(ctx context.Context, coordinates []*ErrorMessageCoordinate) (*ErrorReplayResult, error) {
+	return c.browser.replay(ctx, coordinates)
+}
+
+func (c *errorConsumerGroup) SkipErrorMessages(coordinates []*ErrorMessageCoordinate) error {
+	return c.browser.skip(coordinates)
+}
+
+func (c *errorConsumerGroup) ReplayMatchingErrorMessages(ctx context.Context, filter *ErrorMessageFilter, dryRun bool) (*ErrorReplayResult, error) {
+	return c.browser.replayMatching(ctx, filter, dryRun)
 }
 
 func (c *errorConsumerGroup) existsErrorTopic() bool {
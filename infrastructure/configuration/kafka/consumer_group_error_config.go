@@ -13,6 +13,9 @@ type ConsumerGroupErrorConfig struct {
 	TargetTopic                       string        `json:"targetTopic"`
 	Cron                              string        `json:"cron"`
 	MaxErrorCount                     int           `json:"maxErrorCount"`
+	ParkingLotTopic                   string        `json:"parkingLotTopic"`
+	StatusTopic                       string        `json:"statusTopic"`
+	RunReportHistorySize              int           `json:"runReportHistorySize"`
 	Cluster                           string        `json:"cluster"`
 	CloseConsumerWhenThereIsNoMessage time.Duration `json:"closeConsumerWhenThereIsNoMessage"`
 	CloseConsumerWhenMessageIsNew     time.Duration `json:"closeConsumerWhenMessageIsNew"`
@@ -24,6 +27,14 @@ type ConsumerGroupErrorConfig struct {
 	HeartbeatInterval                 time.Duration `json:"heartbeatInterval"`
 }
 
+func (c *ConsumerGroupErrorConfig) IsDefinedParkingLotTopic() bool {
+	return len(c.ParkingLotTopic) > 0
+}
+
+func (c *ConsumerGroupErrorConfig) IsDefinedStatusTopic() bool {
+	return len(c.StatusTopic) > 0
+}
+
 type ConsumerGroupErrorConfigMap map[string]*ConsumerGroupErrorConfig
 
 func (c ConsumerGroupErrorConfigMap) GetConfigWithDefault(name string) (*ConsumerGroupErrorConfig, error) {
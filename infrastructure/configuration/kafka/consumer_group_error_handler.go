@@ -12,54 +12,44 @@ import (
 type errorConsumerGroupHandler struct {
 	consumerGroupErrorConfig *ConsumerGroupErrorConfig
 	errorTopicConsumerMap    map[string]Consumer
+	producer                 SyncProducer
+	registry                 *errorMessageRegistry
+	runRecorder              *errorConsumerRunRecorder
 
 	//  create in newErrorConsumerGroupHandler
-	state *ConsumerGroupHandlerState
+	state *consumerGroupHandlerStateTracker
 }
 
-func (handler *errorConsumerGroupHandler) GetState() *ConsumerGroupHandlerState {
-	return handler.state
+func (handler *errorConsumerGroupHandler) Snapshot() *ConsumerGroupHandlerState {
+	return handler.state.Snapshot()
 }
 
 func newErrorConsumerGroupHandler(
 	consumerGroupErrorConfig *ConsumerGroupErrorConfig,
 	errorTopicConsumerMap map[string]Consumer,
+	producer SyncProducer,
+	registry *errorMessageRegistry,
+	runRecorder *errorConsumerRunRecorder,
 ) consumerGroupHandler {
 	return &errorConsumerGroupHandler{
 		consumerGroupErrorConfig: consumerGroupErrorConfig,
 		errorTopicConsumerMap:    errorTopicConsumerMap,
-		state: &ConsumerGroupHandlerState{
-			GroupId:             consumerGroupErrorConfig.GroupId,
-			Status:              ConsumerGroupHandlerCreated,
-			CreatedDate:         time.Now(),
-			ConsumerTopicStates: make(map[string]*ConsumerGroupHandlerTopicState),
-		},
+		producer:                 producer,
+		registry:                 registry,
+		runRecorder:              runRecorder,
+		state:                    newConsumerGroupHandlerStateTracker(consumerGroupErrorConfig.GroupId),
 	}
 }
 
 func (handler *errorConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
-	for topic, partitions := range session.Claims() {
-		for _, partition := range partitions {
-			key := getTopicPartitionKey(topic, partition)
-			handler.state.ConsumerTopicStates[key] = &ConsumerGroupHandlerTopicState{
-				Topic:       topic,
-				Partition:   partition,
-				Status:      ConsumerGroupHandlerTopicCreated,
-				CreatedDate: time.Now(),
-			}
-		}
-	}
-	handler.state.Status = ConsumerGroupHandlerStarted
+	handler.state.started(session.Claims())
 	return nil
 }
 
 func (handler *errorConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	topic := claim.Topic()
 	partition := claim.Partition()
-	key := getTopicPartitionKey(topic, partition)
-	state := handler.state.ConsumerTopicStates[key]
-	state.Status = ConsumerGroupHandlerTopicListening
-	state.ListeningDate = time.Now()
+	handler.state.listening(claim)
 	consumer := handler.errorTopicConsumerMap[topic]
 	for {
 		select {
@@ -67,44 +57,100 @@ func (handler *errorConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGr
 			if message == nil {
 				continue
 			}
-			state.LatestConsumedOffset = message.Offset
-			state.LatestConsumedDate = time.Now()
-
 			if time.Since(message.Timestamp).Nanoseconds() < handler.consumerGroupErrorConfig.CloseConsumerWhenMessageIsNew.Nanoseconds() {
-				state.Status = ConsumerGroupHandlerTopicNewMessage
+				handler.state.consumed(message, ConsumerGroupHandlerTopicNewMessage)
+				handler.runRecorder.record(topic, errorConsumerRunSkippedAsNew)
 				continue
 			}
-			state.Status = ConsumerGroupHandlerTopicStarted
+			handler.state.consumed(message, ConsumerGroupHandlerTopicStarted)
 			consumerMessage := &ConsumerMessage{ConsumerMessage: message, GroupId: handler.consumerGroupErrorConfig.GroupId}
 
+			if status := handler.registry.get(message.Topic, message.Partition, message.Offset); status != ErrorMessagePending {
+				log.Infof("Error message already handled manually, status: %s, topic: %s, partition: %d, offset: %d", status, message.Topic, message.Partition, message.Offset)
+				session.MarkMessage(message, "")
+				handler.runRecorder.record(topic, errorConsumerRunManuallyHandled)
+				continue
+			}
 			ctx := context.Background()
 			errorCount := getErrorCount(consumerMessage)
+			original := getOriginalCoordinates(consumerMessage)
 
+			if isPermanentError(consumerMessage) {
+				log.Errorf("Permanent error is not requeued, topic: %s, original topic: %s, partition: %d, offset: %d, first failure: %s, err: %s",
+					message.Topic, original.Topic, original.Partition, original.Offset, original.FirstFailureTime, getErrorMessage(consumerMessage))
+				if !handler.park(session.Context(), consumerMessage) {
+					continue
+				}
+				session.MarkMessage(message, "")
+				handler.runRecorder.record(topic, errorConsumerRunDroppedPermanent)
+				continue
+			}
 			if errorCount > handler.consumerGroupErrorConfig.MaxErrorCount {
 				err := errors.New(getErrorMessage(consumerMessage))
 				reachedMaxRetryErrorCountErr := fmt.Errorf("reached max error count, errRetriedCount: %d", errorCount)
 				joinedErr := errors.Join(err, reachedMaxRetryErrorCountErr)
-				log.Errorf("Reached max retry count, topic: %s, err: %s", handler.consumerGroupErrorConfig.Topics, joinedErr)
+				log.Errorf("Reached max retry count, topic: %s, original topic: %s, partition: %d, offset: %d, first failure: %s, err: %s",
+					handler.consumerGroupErrorConfig.Topics, original.Topic, original.Partition, original.Offset, original.FirstFailureTime, joinedErr)
+				if !handler.park(session.Context(), consumerMessage) {
+					continue
+				}
+				session.MarkMessage(message, "")
+				handler.runRecorder.record(topic, errorConsumerRunDroppedOverMaxCount)
+				continue
+			}
+			claimed, err := handler.registry.claimReplay(consumerMessage)
+			if err != nil {
+				log.Errorf("Replay claim could not be saved, topic: %s, partition: %d, offset: %d, err: %s", message.Topic, message.Partition, message.Offset, err.Error())
 				session.MarkMessage(message, "")
+				handler.runRecorder.record(topic, errorConsumerRunFailed)
 				continue
 			}
-			if err := processMessage(ctx, consumer, consumerMessage, handler.consumerGroupErrorConfig.MaxProcessingTime); err != nil {
+			if !claimed {
+				log.Infof("Error message was already replayed, original topic: %s, partition: %d, offset: %d, error count: %d", original.Topic, original.Partition, original.Offset, errorCount)
+				session.MarkMessage(message, "")
+				handler.runRecorder.record(topic, errorConsumerRunDuplicate)
+				continue
+			}
+			if err := processMessage(ctx, consumer, consumerMessage, handler.consumerGroupErrorConfig.MaxProcessingTime, nil, nil, nil); err != nil {
 				log.Errorf("Reached max retry count, topic: %s, err: %s", handler.consumerGroupErrorConfig.Topics, err.Error())
+				if releaseErr := handler.registry.releaseReplay(consumerMessage); releaseErr != nil {
+					log.Errorf("Replay claim could not be released, topic: %s, partition: %d, offset: %d, err: %s", message.Topic, message.Partition, message.Offset, releaseErr.Error())
+				}
+				handler.runRecorder.record(topic, errorConsumerRunFailed)
+			} else {
+				handler.runRecorder.record(topic, errorConsumerRunRequeued)
 			}
 			session.MarkMessage(message, "")
 		case <-session.Context().Done():
-			state.Status = ConsumerGroupHandlerTopicClosed
+			handler.state.topicClosed(topic, partition)
 			return nil
 		}
 	}
 }
 
-func (handler *errorConsumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
-	for _, state := range handler.state.ConsumerTopicStates {
-		state.Status = ConsumerGroupHandlerTopicClosed
-		state.ClosedDate = time.Now()
+// park moves a message that exhausted its error count to the parking lot topic, keeping every header for a manual replay.
+func (handler *errorConsumerGroupHandler) park(ctx context.Context, message *ConsumerMessage) bool {
+	if !handler.consumerGroupErrorConfig.IsDefinedParkingLotTopic() {
+		return true
+	}
+	parkingLotTopic := handler.consumerGroupErrorConfig.ParkingLotTopic
+	for {
+		err := sendMessageToTopic(handler.producer, message, parkingLotTopic, headersForParkingLot(message))
+		if err == nil {
+			log.Infof("Message moved to parking lot topic: %s, topic: %s, partition: %d, offset: %d", parkingLotTopic, message.Topic, message.Partition, message.Offset)
+			errorConsumerParkedMessageCounter.WithLabelValues(message.GroupId, message.Topic).Inc()
+			return true
+		}
+		log.Errorf("An error occurred when sent message to parking lot topic: %s, topic: %s, partition: %d, offset: %d, err: %s", parkingLotTopic, message.Topic, message.Partition, message.Offset, err.Error())
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return false
+		}
 	}
-	handler.state.Status = ConsumerGroupHandlerClosed
-	handler.state.ClosedDate = time.Now()
+}
+
+func (handler *errorConsumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	handler.state.closed()
 	return nil
 }
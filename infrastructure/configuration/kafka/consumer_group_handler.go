@@ -3,6 +3,8 @@ package kafka
 import (
 	"context"
 	"github.com/IBM/sarama"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"sync"
 	"time"
 )
 
@@ -10,88 +12,428 @@ type consumerGroupHandler interface {
 	Setup(sarama.ConsumerGroupSession) error
 	Cleanup(sarama.ConsumerGroupSession) error
 	ConsumeClaim(sarama.ConsumerGroupSession, sarama.ConsumerGroupClaim) error
-	GetState() *ConsumerGroupHandlerState
+	Snapshot() *ConsumerGroupHandlerState
 }
 
 type consumerGroupHandlerImpl struct {
 	consumerTopicConfig *ConsumerGroupConfig
 	consumers           *ConsumerGroupConsumers
 	producer            SyncProducer
+	transactions        *transactionalProducer
+	pauser              *partitionPauser
+	abandonedHandlers   *abandonedHandlerTracker
+	orderedRetry        *orderedRetryRegistry
 
-	state *ConsumerGroupHandlerState
+	state *consumerGroupHandlerStateTracker
 }
 
 func newConsumerGroupHandlerImpl(
 	consumerTopicConfig *ConsumerGroupConfig,
 	consumers *ConsumerGroupConsumers,
 	producer SyncProducer,
+	transactions *transactionalProducer,
+	pauser *partitionPauser,
 ) consumerGroupHandler {
+	var orderedRetry *orderedRetryRegistry
+	if consumerTopicConfig.OrderedRetry {
+		orderedRetry = newOrderedRetryRegistry(consumerTopicConfig.GroupId, consumerTopicConfig.OrderedRetryMaxHold)
+	}
 	return &consumerGroupHandlerImpl{
 		consumerTopicConfig: consumerTopicConfig,
 		consumers:           consumers,
 		producer:            producer,
-		state: &ConsumerGroupHandlerState{
-			GroupId:             consumerTopicConfig.GroupId,
-			Status:              ConsumerGroupHandlerCreated,
-			ConsumerTopicStates: make(map[string]*ConsumerGroupHandlerTopicState),
-		},
+		transactions:        transactions,
+		pauser:              pauser,
+		abandonedHandlers:   newAbandonedHandlerTracker(consumerTopicConfig.GroupId, consumerTopicConfig.MaxAbandonedHandlers, consumerTopicConfig.TimeoutPolicy, pauser),
+		orderedRetry:        orderedRetry,
+		state:               newConsumerGroupHandlerStateTracker(consumerTopicConfig.GroupId),
 	}
 }
 
 func (handler *consumerGroupHandlerImpl) Setup(session sarama.ConsumerGroupSession) error {
-	for topic, partitions := range session.Claims() {
-		for _, partition := range partitions {
-			topicPartitionKey := getTopicPartitionKey(topic, partition)
-			handler.state.ConsumerTopicStates[topicPartitionKey] = &ConsumerGroupHandlerTopicState{
-				Topic:     topic,
-				Partition: partition,
-				Status:    ConsumerGroupHandlerTopicCreated,
-			}
-		}
+	handler.state.started(session.Claims())
+	if handler.orderedRetry != nil {
+		handler.orderedRetry.releaseRevoked(session.Claims())
 	}
-	handler.state.Status = ConsumerGroupHandlerStarted
 	return nil
 }
 
 func (handler *consumerGroupHandlerImpl) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	topic := claim.Topic()
 	partition := claim.Partition()
-	key := getTopicPartitionKey(topic, partition)
-	state := handler.state.ConsumerTopicStates[key]
-	state.Status = ConsumerGroupHandlerTopicListening
-	state.ListeningDate = time.Now()
-	consumer := handler.consumers.Consumer
+	handler.state.listening(claim)
+	handler.pauser.reapply(topic, partition)
+	if handler.consumerTopicConfig.Batch {
+		return handler.consumeClaimBatch(session, claim)
+	}
+	if handler.consumerTopicConfig.Concurrency > 1 {
+		return handler.consumeClaimConcurrently(session, claim)
+	}
 	for {
 		select {
 		case message := <-claim.Messages():
 			if message == nil {
 				continue
 			}
-			state.Status = ConsumerGroupHandlerTopicStarted
-			state.LatestConsumedOffset = message.Offset
-			state.LatestConsumedDate = time.Now()
+			if !handler.pauser.waitWhilePaused(session.Context(), message.Topic, message.Partition) {
+				continue
+			}
+			if !handler.waitUntilRetryDue(session.Context(), message) {
+				continue
+			}
+			handler.state.consumed(message, ConsumerGroupHandlerTopicStarted)
 
-			consumerMessage := &ConsumerMessage{ConsumerMessage: message, GroupId: handler.consumerTopicConfig.GroupId}
-			if err := processMessage(context.Background(), consumer, consumerMessage, handler.consumerTopicConfig.MaxProcessingTime); err != nil {
-				processConsumedMessageError(context.Background(), consumerMessage, err, handler.producer, handler.consumerTopicConfig)
+			if !handler.handleMessage(session.Context(), &ConsumerMessage{ConsumerMessage: message, GroupId: handler.consumerTopicConfig.GroupId}) {
+				continue
 			}
 			session.MarkMessage(message, "")
+			handler.flushOffsetsWhenIdle(session.Context(), claim)
 		case <-session.Context().Done():
-			state.Status = ConsumerGroupHandlerTopicClosed
+			handler.flushOffsets(session.Context(), claim.Topic(), claim.Partition())
+			handler.state.topicClosed(claim.Topic(), claim.Partition())
 			return nil
 		}
 	}
 }
 
-func (handler *consumerGroupHandlerImpl) Cleanup(sarama.ConsumerGroupSession) error {
-	handler.state.Status = ConsumerGroupHandlerClosed
-	for _, state := range handler.state.ConsumerTopicStates {
-		state.Status = ConsumerGroupHandlerTopicClosed
-		state.ClosedDate = time.Now()
+func (handler *consumerGroupHandlerImpl) consumeClaimConcurrently(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	concurrency := handler.consumerTopicConfig.Concurrency
+	offsetTracker := newPartitionOffsetTracker(session)
+	workerChannels := make([]chan *ConsumerMessage, concurrency)
+	var waitGroup sync.WaitGroup
+	for i := range workerChannels {
+		workerChannels[i] = make(chan *ConsumerMessage, 1)
+		waitGroup.Add(1)
+		go func(messages <-chan *ConsumerMessage) {
+			defer waitGroup.Done()
+			for message := range messages {
+				if session.Context().Err() != nil {
+					continue
+				}
+				if handler.handleMessage(session.Context(), message) {
+					offsetTracker.done(message.ConsumerMessage)
+				}
+			}
+		}(workerChannels[i])
+	}
+	defer func() {
+		for _, workerChannel := range workerChannels {
+			close(workerChannel)
+		}
+		waitGroup.Wait()
+	}()
+	for {
+		select {
+		case message := <-claim.Messages():
+			if message == nil {
+				continue
+			}
+			if !handler.pauser.waitWhilePaused(session.Context(), message.Topic, message.Partition) {
+				continue
+			}
+			if !handler.waitUntilRetryDue(session.Context(), message) {
+				continue
+			}
+			handler.state.consumed(message, ConsumerGroupHandlerTopicStarted)
+
+			consumerMessage := &ConsumerMessage{ConsumerMessage: message, GroupId: handler.consumerTopicConfig.GroupId}
+			offsetTracker.add(message)
+			select {
+			case workerChannels[getWorkerIndex(message, concurrency)] <- consumerMessage:
+			case <-session.Context().Done():
+				handler.state.topicClosed(claim.Topic(), claim.Partition())
+				return nil
+			}
+		case <-session.Context().Done():
+			handler.state.topicClosed(claim.Topic(), claim.Partition())
+			return nil
+		}
+	}
+}
+
+func (handler *consumerGroupHandlerImpl) consumeClaimBatch(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	batchSize := handler.consumerTopicConfig.BatchSize
+	messages := make([]*ConsumerMessage, 0, batchSize)
+	var batchMaxWait <-chan time.Time
+	flush := func() {
+		if len(messages) == 0 {
+			return
+		}
+		if handler.handleBatch(session.Context(), messages) {
+			session.MarkMessage(messages[len(messages)-1].ConsumerMessage, "")
+			handler.flushOffsetsWhenIdle(session.Context(), claim)
+		}
+		messages = make([]*ConsumerMessage, 0, batchSize)
+		batchMaxWait = nil
+	}
+	for {
+		select {
+		case message := <-claim.Messages():
+			if message == nil {
+				continue
+			}
+			if !handler.pauser.waitWhilePaused(session.Context(), message.Topic, message.Partition) {
+				continue
+			}
+			if handler.retryDueIn(message) > 0 {
+				flush()
+			}
+			if !handler.waitUntilRetryDue(session.Context(), message) {
+				continue
+			}
+			handler.state.consumed(message, ConsumerGroupHandlerTopicStarted)
+
+			messages = append(messages, &ConsumerMessage{ConsumerMessage: message, GroupId: handler.consumerTopicConfig.GroupId})
+			if len(messages) == 1 {
+				batchMaxWait = time.After(handler.consumerTopicConfig.BatchMaxWait)
+			}
+			if len(messages) >= batchSize {
+				flush()
+			}
+		case <-batchMaxWait:
+			flush()
+		case <-session.Context().Done():
+			handler.flushOffsets(session.Context(), claim.Topic(), claim.Partition())
+			handler.state.topicClosed(claim.Topic(), claim.Partition())
+			return nil
+		}
+	}
+}
+
+func (handler *consumerGroupHandlerImpl) handleBatch(ctx context.Context, messages []*ConsumerMessage) bool {
+	pending := messages
+	for len(pending) > 0 {
+		var round []*ConsumerMessage
+		round, pending = handler.splitBatchRound(pending)
+		if !handler.handleBatchRound(ctx, round) {
+			return false
+		}
+	}
+	return handler.commitOffset(ctx, messages[len(messages)-1])
+}
+
+// splitBatchRound passes one message per key to the batch consumer with ordered retry. The round ends before the first repeated key,
+// so a later message of the key is diverted when the earlier one fails and no offset of the next rounds is committed early.
+func (handler *consumerGroupHandlerImpl) splitBatchRound(messages []*ConsumerMessage) ([]*ConsumerMessage, []*ConsumerMessage) {
+	if handler.orderedRetry == nil {
+		return messages, nil
+	}
+	keys := make(map[string]struct{}, len(messages))
+	for i, message := range messages {
+		if len(message.Key) == 0 {
+			continue
+		}
+		if _, exists := keys[string(message.Key)]; exists {
+			return messages[:i], messages[i:]
+		}
+		keys[string(message.Key)] = struct{}{}
+	}
+	return messages, nil
+}
+
+func (handler *consumerGroupHandlerImpl) handleBatchRound(ctx context.Context, messages []*ConsumerMessage) bool {
+	processable := make([]*ConsumerMessage, 0, len(messages))
+	for _, message := range messages {
+		if handler.waitsBehindRetry(message) {
+			if !handler.divertBehindRetry(ctx, message) {
+				return false
+			}
+			continue
+		}
+		processable = append(processable, message)
+	}
+	if len(processable) == 0 {
+		return true
+	}
+	failedMessages := processBatchMessages(context.Background(), handler.consumers.BatchConsumer, processable, handler.consumerTopicConfig.MaxProcessingTime, handler.abandonedHandlers)
+	for _, message := range processable {
+		if err, failed := failedMessages[message]; failed {
+			if !handler.handOff(ctx, message, err) {
+				return false
+			}
+			continue
+		}
+		observeEndToEndDelay(message)
+		handler.releaseOrderedRetry(message)
+	}
+	return true
+}
+
+func (handler *consumerGroupHandlerImpl) handleMessage(ctx context.Context, consumerMessage *ConsumerMessage) bool {
+	if handler.waitsBehindRetry(consumerMessage) {
+		return handler.divertBehindRetry(ctx, consumerMessage)
+	}
+	err := processMessage(context.Background(), handler.consumers.Consumer, consumerMessage, handler.consumerTopicConfig.MaxProcessingTime, handler.consumerTopicConfig.LocalRetry, handler.consumerTopicConfig.ClassifyError, handler.abandonedHandlers)
+	if err == nil {
+		observeEndToEndDelay(consumerMessage)
+		handler.releaseOrderedRetry(consumerMessage)
+		return handler.commitOffset(ctx, consumerMessage)
+	}
+	return handler.handOff(ctx, consumerMessage, err)
+}
+
+func (handler *consumerGroupHandlerImpl) handOff(ctx context.Context, consumerMessage *ConsumerMessage, err error) bool {
+	return handler.sendWithBackoff(ctx, consumerMessage, func(producer SyncProducer) (string, error) {
+		return processConsumedMessageError(ctx, consumerMessage, err, producer, handler.consumerTopicConfig)
+	})
+}
+
+// sendWithBackoff blocks the partition until the message is sent when the commit mode requires it, the key stays held only while the message is in retry.
+func (handler *consumerGroupHandlerImpl) sendWithBackoff(ctx context.Context, consumerMessage *ConsumerMessage, send func(producer SyncProducer) (string, error)) bool {
+	handler.holdOrderedRetry(consumerMessage)
+	topic, handOffErr := handler.send(consumerMessage, send)
+	if handOffErr != nil && handler.consumerTopicConfig.CommitMode.BlocksOnHandOffError() {
+		backoff := handler.consumerTopicConfig.HandOffBackoff
+		for handOffErr != nil {
+			log.Errorf("Message could not be handed off, partition is blocked, topic: %s, partition: %d, offset: %d, retry in: %s, err: %s",
+				consumerMessage.Topic, consumerMessage.Partition, consumerMessage.Offset, backoff, handOffErr.Error())
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				handler.releaseOrderedRetry(consumerMessage)
+				return false
+			}
+			topic, handOffErr = handler.send(consumerMessage, send)
+			backoff = min(2*backoff, handler.consumerTopicConfig.HandOffMaxBackoff)
+		}
+	}
+	if handOffErr != nil || handler.consumerTopicConfig.GetRetryTierIndex(topic) < 0 {
+		handler.releaseOrderedRetry(consumerMessage)
+	}
+	return true
+}
+
+// send publishes the hand off and commits the message offset in the same transaction with the exactly once commit mode.
+func (handler *consumerGroupHandlerImpl) send(consumerMessage *ConsumerMessage, send func(producer SyncProducer) (string, error)) (string, error) {
+	if handler.transactions == nil {
+		return send(handler.producer)
+	}
+	var topic string
+	err := handler.transactions.run(consumerMessage, func(producer SyncProducer) error {
+		var err error
+		topic, err = send(producer)
+		return err
+	})
+	return topic, err
+}
+
+// commitOffset keeps the offset for the next transaction, the partition is flushed once enough offsets are waiting.
+func (handler *consumerGroupHandlerImpl) commitOffset(ctx context.Context, consumerMessage *ConsumerMessage) bool {
+	if handler.transactions == nil {
+		return true
+	}
+	if !handler.transactions.markOffset(consumerMessage) {
+		return true
+	}
+	return handler.flushOffsets(ctx, consumerMessage.Topic, consumerMessage.Partition)
+}
+
+// flushOffsetsWhenIdle commits the waiting offsets once the claim has no buffered message left.
+func (handler *consumerGroupHandlerImpl) flushOffsetsWhenIdle(ctx context.Context, claim sarama.ConsumerGroupClaim) {
+	if handler.transactions == nil || len(claim.Messages()) > 0 {
+		return
+	}
+	handler.flushOffsets(ctx, claim.Topic(), claim.Partition())
+}
+
+func (handler *consumerGroupHandlerImpl) flushOffsets(ctx context.Context, topic string, partition int32) bool {
+	if handler.transactions == nil {
+		return true
+	}
+	backoff := handler.consumerTopicConfig.HandOffBackoff
+	for {
+		err := handler.transactions.flush(topic, partition)
+		if err == nil {
+			return true
+		}
+		log.Errorf("Offsets could not be committed in transaction, partition is blocked, topic: %s, partition: %d, retry in: %s, err: %s",
+			topic, partition, backoff, err.Error())
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return false
+		}
+		backoff = min(2*backoff, handler.consumerTopicConfig.HandOffMaxBackoff)
+	}
+}
+
+func (handler *consumerGroupHandlerImpl) waitsBehindRetry(message *ConsumerMessage) bool {
+	if handler.orderedRetry == nil || len(message.Key) == 0 {
+		return false
+	}
+	if isMainTopic(message, handler.consumerTopicConfig) {
+		return handler.orderedRetry.isHeld(string(message.Key))
+	}
+	return !handler.orderedRetry.isHead(string(message.Key), getRetrySequence(message))
+}
+
+// divertBehindRetry queues the message in the retry tier without processing it, so it cannot overtake an earlier message of its key.
+func (handler *consumerGroupHandlerImpl) divertBehindRetry(ctx context.Context, message *ConsumerMessage) bool {
+	tier := handler.consumerTopicConfig.RetryTiers[max(handler.consumerTopicConfig.GetRetryTierIndex(message.Topic), 0)]
+	retriedCount := 0
+	if isRetryTopic(message, handler.consumerTopicConfig) {
+		retriedCount = getRetriedCount(message) - 1
+	}
+	log.Infof("Key has messages in retry, diverting message to retry topic: %s, topic: %s, partition: %d, offset: %d, key: %s",
+		tier.Topic, message.Topic, message.Partition, message.Offset, string(message.Key))
+	consumerOrderedRetryDivertedCounter.WithLabelValues(message.GroupId, message.Topic).Inc()
+	return handler.sendWithBackoff(ctx, message, func(producer SyncProducer) (string, error) {
+		return tier.Topic, sendMessageToTopic(producer, message, tier.Topic, headersForOrderedRetry(message, retriedCount, tier.GetDelay(max(retriedCount, 0))))
+	})
+}
+
+func (handler *consumerGroupHandlerImpl) holdOrderedRetry(message *ConsumerMessage) {
+	if handler.orderedRetry == nil || len(message.Key) == 0 || getRetrySequence(message) != 0 {
+		return
+	}
+	setRetrySequence(message, handler.orderedRetry.hold(string(message.Key), message.Topic, message.Partition))
+}
+
+func (handler *consumerGroupHandlerImpl) releaseOrderedRetry(message *ConsumerMessage) {
+	if handler.orderedRetry == nil || len(message.Key) == 0 {
+		return
+	}
+	handler.orderedRetry.release(string(message.Key), getRetrySequence(message))
+}
+
+func (handler *consumerGroupHandlerImpl) retryDueIn(message *sarama.ConsumerMessage) time.Duration {
+	if handler.consumerTopicConfig.GetRetryTierIndex(message.Topic) < 0 {
+		return 0
+	}
+	retryAfter := getRetryAfter(message)
+	if retryAfter.IsZero() {
+		return 0
+	}
+	return time.Until(retryAfter)
+}
+
+// waitUntilRetryDue stops fetching the retry partition until its head message is due.
+func (handler *consumerGroupHandlerImpl) waitUntilRetryDue(ctx context.Context, message *sarama.ConsumerMessage) bool {
+	dueIn := handler.retryDueIn(message)
+	if dueIn <= 0 {
+		return true
+	}
+	log.Infof("Retry message is not due yet, pausing partition, topic: %s, partition: %d, offset: %d, due in: %s", message.Topic, message.Partition, message.Offset, dueIn)
+	handler.pauser.pauseFetch(message.Topic, message.Partition)
+	defer handler.pauser.resumeFetch(message.Topic, message.Partition)
+	timer := time.NewTimer(dueIn)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (handler *consumerGroupHandlerImpl) Cleanup(session sarama.ConsumerGroupSession) error {
+	if handler.transactions != nil {
+		handler.transactions.dropPendingOffsets(session.Claims())
 	}
+	handler.state.closed()
 	return nil
 }
 
-func (handler *consumerGroupHandlerImpl) GetState() *ConsumerGroupHandlerState {
-	return handler.state
+func (handler *consumerGroupHandlerImpl) Snapshot() *ConsumerGroupHandlerState {
+	return handler.state.Snapshot()
 }
@@ -0,0 +1,190 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/sarama"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestConsumerMessage(topic string, key string, offset int64) *ConsumerMessage {
+	return &ConsumerMessage{
+		ConsumerMessage: &sarama.ConsumerMessage{Topic: topic, Key: []byte(key), Value: []byte(key), Offset: offset},
+		GroupId:         "group",
+	}
+}
+
+func getMessageKeys(messages []*ConsumerMessage) []string {
+	keys := make([]string, 0, len(messages))
+	for _, message := range messages {
+		keys = append(keys, string(message.Key))
+	}
+	return keys
+}
+
+func TestConsumerGroupHandlerImpl_SplitBatchRound(t *testing.T) {
+	tests := []struct {
+		name            string
+		orderedRetry    bool
+		keys            []string
+		expectedRound   []string
+		expectedPending []string
+	}{
+		{name: "without ordered retry", orderedRetry: false, keys: []string{"a", "b", "a"}, expectedRound: []string{"a", "b", "a"}, expectedPending: []string{}},
+		{name: "distinct keys", orderedRetry: true, keys: []string{"a", "b", "c"}, expectedRound: []string{"a", "b", "c"}, expectedPending: []string{}},
+		{name: "repeated key", orderedRetry: true, keys: []string{"a", "b", "a", "c"}, expectedRound: []string{"a", "b"}, expectedPending: []string{"a", "c"}},
+		{name: "repeated first key", orderedRetry: true, keys: []string{"a", "a"}, expectedRound: []string{"a"}, expectedPending: []string{"a"}},
+		{name: "empty keys are not grouped", orderedRetry: true, keys: []string{"", "a", "", "b"}, expectedRound: []string{"", "a", "", "b"}, expectedPending: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := &consumerGroupHandlerImpl{}
+			if test.orderedRetry {
+				handler.orderedRetry = newOrderedRetryRegistry("group", time.Hour)
+			}
+			messages := make([]*ConsumerMessage, 0, len(test.keys))
+			for i, key := range test.keys {
+				messages = append(messages, newTestConsumerMessage("topic", key, int64(i)))
+			}
+
+			round, pending := handler.splitBatchRound(messages)
+
+			if keys := getMessageKeys(round); !slices.Equal(keys, test.expectedRound) {
+				t.Errorf("round = %v, expected %v", keys, test.expectedRound)
+			}
+			if keys := getMessageKeys(pending); !slices.Equal(keys, test.expectedPending) {
+				t.Errorf("pending = %v, expected %v", keys, test.expectedPending)
+			}
+		})
+	}
+}
+
+// recordingBatchConsumer fails the messages of the given keys and records the keys of every batch it receives.
+type recordingBatchConsumer struct {
+	mutex      sync.Mutex
+	failedKeys map[string]struct{}
+	batches    [][]string
+}
+
+func (consumer *recordingBatchConsumer) ConsumeBatch(_ context.Context, messages []*ConsumerMessage) error {
+	consumer.mutex.Lock()
+	defer consumer.mutex.Unlock()
+	consumer.batches = append(consumer.batches, getMessageKeys(messages))
+	batchErr := NewBatchError()
+	for _, message := range messages {
+		if _, exists := consumer.failedKeys[string(message.Key)]; exists {
+			batchErr.Errors[message] = errors.New("consume failed")
+		}
+	}
+	if len(batchErr.Errors) == 0 {
+		return nil
+	}
+	return batchErr
+}
+
+func TestConsumerGroupHandlerImpl_HandleBatch(t *testing.T) {
+	tests := []struct {
+		name            string
+		orderedRetry    bool
+		keys            []string
+		failedKeys      []string
+		expectedBatches [][]string
+		expectedSent    []string
+		expectedHeld    []string
+	}{
+		{
+			name:            "all consumed",
+			orderedRetry:    true,
+			keys:            []string{"a", "b", "a"},
+			expectedBatches: [][]string{{"a", "b"}, {"a"}},
+			expectedSent:    []string{},
+		},
+		{
+			name:            "failed message is retried without ordered retry",
+			orderedRetry:    false,
+			keys:            []string{"a", "b", "a"},
+			failedKeys:      []string{"a"},
+			expectedBatches: [][]string{{"a", "b", "a"}},
+			expectedSent:    []string{"a", "a"},
+		},
+		{
+			name:            "later message of a failed key is diverted",
+			orderedRetry:    true,
+			keys:            []string{"a", "b", "a", "c"},
+			failedKeys:      []string{"a"},
+			expectedBatches: [][]string{{"a", "b"}, {"c"}},
+			expectedSent:    []string{"a", "a"},
+			expectedHeld:    []string{"a"},
+		},
+		{
+			name:            "other keys are not held",
+			orderedRetry:    true,
+			keys:            []string{"a", "b", "b"},
+			failedKeys:      []string{"a"},
+			expectedBatches: [][]string{{"a", "b"}, {"b"}},
+			expectedSent:    []string{"a"},
+			expectedHeld:    []string{"a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &ConsumerGroupConfig{
+				GroupId:           "group",
+				Name:              "topic",
+				Error:             "topic.error",
+				MaxProcessingTime: time.Second,
+				RetryTiers:        []*RetryTier{{Topic: "topic.retry", Delay: time.Second, Attempts: 3}},
+			}
+			failedKeys := make(map[string]struct{})
+			for _, key := range test.failedKeys {
+				failedKeys[key] = struct{}{}
+			}
+			consumer := &recordingBatchConsumer{failedKeys: failedKeys}
+			producer := newFakeSyncProducer(nil)
+			handler := &consumerGroupHandlerImpl{
+				consumerTopicConfig: config,
+				consumers:           &ConsumerGroupConsumers{BatchConsumer: consumer},
+				producer:            producer,
+			}
+			if test.orderedRetry {
+				handler.orderedRetry = newOrderedRetryRegistry("group", time.Hour)
+			}
+			messages := make([]*ConsumerMessage, 0, len(test.keys))
+			for i, key := range test.keys {
+				messages = append(messages, newTestConsumerMessage("topic", key, int64(i)))
+			}
+
+			if !handler.handleBatch(context.Background(), messages) {
+				t.Fatal("batch is not handled")
+			}
+
+			if len(consumer.batches) != len(test.expectedBatches) {
+				t.Fatalf("batches = %v, expected %v", consumer.batches, test.expectedBatches)
+			}
+			for i, batch := range consumer.batches {
+				if !slices.Equal(batch, test.expectedBatches[i]) {
+					t.Errorf("batch %d = %v, expected %v", i, batch, test.expectedBatches[i])
+				}
+			}
+			if sent := producer.sentKeys(); !slices.Equal(sent, test.expectedSent) {
+				t.Errorf("sent keys = %v, expected %v", sent, test.expectedSent)
+			}
+			for _, topic := range producer.sentTopics() {
+				if topic != "topic.retry" {
+					t.Errorf("sent topic = %s, expected topic.retry", topic)
+				}
+			}
+			if handler.orderedRetry != nil {
+				for _, key := range test.keys {
+					expected := slices.Contains(test.expectedHeld, key)
+					if held := handler.orderedRetry.isHeld(key); held != expected {
+						t.Errorf("isHeld(%s) = %t, expected %t", key, held, expected)
+					}
+				}
+			}
+		})
+	}
+}
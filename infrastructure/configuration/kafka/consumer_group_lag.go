@@ -0,0 +1,110 @@
+package kafka
+
+import (
+	"fmt"
+	"github.com/IBM/sarama"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"time"
+)
+
+type consumerGroupLagReporter struct {
+	groupId          string
+	client           Client
+	handler          consumerGroupHandler
+	interval         time.Duration
+	reportedLabelMap map[string][]string
+	stop             chan struct{}
+}
+
+func newConsumerGroupLagReporter(groupId string, client Client, handler consumerGroupHandler, interval time.Duration) *consumerGroupLagReporter {
+	return &consumerGroupLagReporter{
+		groupId:          groupId,
+		client:           client,
+		handler:          handler,
+		interval:         interval,
+		reportedLabelMap: make(map[string][]string),
+		stop:             make(chan struct{}),
+	}
+}
+
+func (r *consumerGroupLagReporter) start() {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.report()
+			case <-r.stop:
+				r.clear()
+				return
+			}
+		}
+	}()
+}
+
+func (r *consumerGroupLagReporter) close() {
+	close(r.stop)
+}
+
+func (r *consumerGroupLagReporter) report() {
+	reportedLabelMap := make(map[string][]string)
+	for key, state := range r.handler.Snapshot().ConsumerTopicStates {
+		if state.Status == ConsumerGroupHandlerTopicClosed || state.ListeningDate == nil {
+			continue
+		}
+		highWatermark, err := r.client.GetOffset(state.Topic, state.Partition, sarama.OffsetNewest)
+		if err != nil {
+			log.Errorf("Consumer lag could not be calculated, groupId: %s, topic: %s, partition: %d, err: %s", r.groupId, state.Topic, state.Partition, err.Error())
+			continue
+		}
+		nextOffset, err := r.getNextOffset(state)
+		if err != nil {
+			log.Errorf("Consumer lag could not be calculated, groupId: %s, topic: %s, partition: %d, err: %s", r.groupId, state.Topic, state.Partition, err.Error())
+			continue
+		}
+		lag := max(highWatermark-nextOffset, 0)
+		var timeLag time.Duration
+		if lag > 0 {
+			timeLag = getTimeLag(state)
+		}
+		labels := []string{r.groupId, state.Topic, fmt.Sprint(state.Partition)}
+		consumerPartitionLag.WithLabelValues(labels...).Set(float64(lag))
+		consumerPartitionTimeLag.WithLabelValues(labels...).Set(timeLag.Seconds())
+		reportedLabelMap[key] = labels
+	}
+	for key, labels := range r.reportedLabelMap {
+		if _, exists := reportedLabelMap[key]; !exists {
+			consumerPartitionLag.DeleteLabelValues(labels...)
+			consumerPartitionTimeLag.DeleteLabelValues(labels...)
+		}
+	}
+	r.reportedLabelMap = reportedLabelMap
+}
+
+func (r *consumerGroupLagReporter) clear() {
+	for _, labels := range r.reportedLabelMap {
+		consumerPartitionLag.DeleteLabelValues(labels...)
+		consumerPartitionTimeLag.DeleteLabelValues(labels...)
+	}
+	r.reportedLabelMap = make(map[string][]string)
+}
+
+// getNextOffset returns the offset after the latest consumed message, the claim's initial offset is used until a message is consumed.
+func (r *consumerGroupLagReporter) getNextOffset(state *ConsumerGroupHandlerTopicState) (int64, error) {
+	if state.LatestConsumedDate != nil {
+		return state.LatestConsumedOffset + 1, nil
+	}
+	if state.InitialOffset >= 0 {
+		return state.InitialOffset, nil
+	}
+	return r.client.GetOffset(state.Topic, state.Partition, state.InitialOffset)
+}
+
+// getTimeLag uses the listening date as a lower bound when the partition has not consumed anything yet.
+func getTimeLag(state *ConsumerGroupHandlerTopicState) time.Duration {
+	if state.LatestConsumedTimestamp != nil {
+		return time.Since(*state.LatestConsumedTimestamp)
+	}
+	return time.Since(*state.ListeningDate)
+}
@@ -0,0 +1,122 @@
+package kafka
+
+import (
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"sync"
+	"time"
+)
+
+// orderedRetryRegistry keeps the keys that have messages in retry on this instance, so later messages of a key wait behind them.
+// The holds only live in memory, a hold is dropped once it is older than the max hold or its partition is assigned elsewhere,
+// since the retry message may be consumed by another instance that cannot release it.
+type orderedRetryRegistry struct {
+	mutex        sync.Mutex
+	groupId      string
+	maxHold      time.Duration
+	nextSequence int64
+	keys         map[string]map[int64]*orderedRetryHold
+}
+
+type orderedRetryHold struct {
+	topic     string
+	partition int32
+	heldAt    time.Time
+}
+
+func newOrderedRetryRegistry(groupId string, maxHold time.Duration) *orderedRetryRegistry {
+	return &orderedRetryRegistry{
+		groupId:      groupId,
+		maxHold:      maxHold,
+		nextSequence: time.Now().UnixNano(),
+		keys:         make(map[string]map[int64]*orderedRetryHold),
+	}
+}
+
+func (registry *orderedRetryRegistry) hold(key string, topic string, partition int32) int64 {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.nextSequence++
+	sequences, exists := registry.keys[key]
+	if !exists {
+		sequences = make(map[int64]*orderedRetryHold)
+		registry.keys[key] = sequences
+		consumerOrderedRetryKeys.WithLabelValues(registry.groupId).Inc()
+	}
+	sequences[registry.nextSequence] = &orderedRetryHold{topic: topic, partition: partition, heldAt: time.Now()}
+	return registry.nextSequence
+}
+
+func (registry *orderedRetryRegistry) isHeld(key string) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.expire(key)
+	_, exists := registry.keys[key]
+	return exists
+}
+
+// isHead reports whether the sequence is the oldest one in retry for the key, unknown sequences are not blocked.
+func (registry *orderedRetryRegistry) isHead(key string, sequence int64) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.expire(key)
+	sequences, exists := registry.keys[key]
+	if !exists {
+		return true
+	}
+	if _, exists = sequences[sequence]; !exists {
+		return true
+	}
+	for other := range sequences {
+		if other < sequence {
+			return false
+		}
+	}
+	return true
+}
+
+func (registry *orderedRetryRegistry) release(key string, sequence int64) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.remove(key, sequence)
+}
+
+// releaseRevoked drops the holds of the partitions that are not claimed by this instance anymore.
+func (registry *orderedRetryRegistry) releaseRevoked(claims map[string][]int32) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	claimed := make(map[string]struct{})
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			claimed[getTopicPartitionKey(topic, partition)] = struct{}{}
+		}
+	}
+	for key, sequences := range registry.keys {
+		for sequence, hold := range sequences {
+			if _, exists := claimed[getTopicPartitionKey(hold.topic, hold.partition)]; !exists {
+				registry.remove(key, sequence)
+			}
+		}
+	}
+}
+
+func (registry *orderedRetryRegistry) expire(key string) {
+	for sequence, hold := range registry.keys[key] {
+		if time.Since(hold.heldAt) > registry.maxHold {
+			log.Errorf("Ordered retry hold expired before its retry message was processed, group: %s, key: %s, held at: %s", registry.groupId, key, hold.heldAt)
+			registry.remove(key, sequence)
+		}
+	}
+}
+
+func (registry *orderedRetryRegistry) remove(key string, sequence int64) {
+	sequences, exists := registry.keys[key]
+	if !exists {
+		return
+	}
+	delete(sequences, sequence)
+	if len(sequences) == 0 {
+		delete(registry.keys, key)
+		consumerOrderedRetryKeys.WithLabelValues(registry.groupId).Dec()
+		log.Infof("Released ordered retry key, group: %s, key: %s", registry.groupId, key)
+	}
+}
@@ -0,0 +1,131 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderedRetryRegistry_IsHead(t *testing.T) {
+	registry := newOrderedRetryRegistry("group", time.Hour)
+	first := registry.hold("key", "topic", 0)
+	second := registry.hold("key", "topic", 0)
+	other := registry.hold("other", "topic", 1)
+
+	tests := []struct {
+		name     string
+		key      string
+		sequence int64
+		expected bool
+	}{
+		{name: "oldest hold of the key", key: "key", sequence: first, expected: true},
+		{name: "later hold of the key", key: "key", sequence: second, expected: false},
+		{name: "hold of another key", key: "other", sequence: other, expected: true},
+		{name: "unknown sequence", key: "key", sequence: 0, expected: true},
+		{name: "unknown key", key: "missing", sequence: first, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := registry.isHead(test.key, test.sequence); actual != test.expected {
+				t.Errorf("isHead = %t, expected %t", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestOrderedRetryRegistry_Release(t *testing.T) {
+	tests := []struct {
+		name          string
+		holds         int
+		release       []int
+		expectedHeld  bool
+		expectedHeads []int
+	}{
+		{name: "no release", holds: 2, release: nil, expectedHeld: true, expectedHeads: []int{0}},
+		{name: "head released", holds: 2, release: []int{0}, expectedHeld: true, expectedHeads: []int{1}},
+		{name: "later released", holds: 3, release: []int{1}, expectedHeld: true, expectedHeads: []int{0}},
+		{name: "all released", holds: 2, release: []int{1, 0}, expectedHeld: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := newOrderedRetryRegistry("group", time.Hour)
+			sequences := make([]int64, 0, test.holds)
+			for i := 0; i < test.holds; i++ {
+				sequences = append(sequences, registry.hold("key", "topic", 0))
+			}
+			for _, i := range test.release {
+				registry.release("key", sequences[i])
+			}
+			if actual := registry.isHeld("key"); actual != test.expectedHeld {
+				t.Errorf("isHeld = %t, expected %t", actual, test.expectedHeld)
+			}
+			for _, i := range test.expectedHeads {
+				if !registry.isHead("key", sequences[i]) {
+					t.Errorf("hold %d is not the head", i)
+				}
+			}
+		})
+	}
+}
+
+func TestOrderedRetryRegistry_Expire(t *testing.T) {
+	tests := []struct {
+		name     string
+		heldFor  time.Duration
+		expected bool
+	}{
+		{name: "within max hold", heldFor: time.Minute, expected: true},
+		{name: "older than max hold", heldFor: 2 * time.Hour, expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := newOrderedRetryRegistry("group", time.Hour)
+			sequence := registry.hold("key", "topic", 0)
+			registry.keys["key"][sequence].heldAt = time.Now().Add(-test.heldFor)
+			if actual := registry.isHeld("key"); actual != test.expected {
+				t.Errorf("isHeld = %t, expected %t", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestOrderedRetryRegistry_ReleaseRevoked(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   map[string][]int32
+		expected map[string]bool
+	}{
+		{
+			name:     "all partitions claimed",
+			claims:   map[string][]int32{"topic": {0, 1}},
+			expected: map[string]bool{"first": true, "second": true},
+		},
+		{
+			name:     "one partition revoked",
+			claims:   map[string][]int32{"topic": {1}},
+			expected: map[string]bool{"first": false, "second": true},
+		},
+		{
+			name:     "other topic claimed",
+			claims:   map[string][]int32{"other": {0, 1}},
+			expected: map[string]bool{"first": false, "second": false},
+		},
+		{
+			name:     "nothing claimed",
+			claims:   map[string][]int32{},
+			expected: map[string]bool{"first": false, "second": false},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := newOrderedRetryRegistry("group", time.Hour)
+			registry.hold("first", "topic", 0)
+			registry.hold("second", "topic", 1)
+			registry.releaseRevoked(test.claims)
+			for key, expected := range test.expected {
+				if actual := registry.isHeld(key); actual != expected {
+					t.Errorf("isHeld(%s) = %t, expected %t", key, actual, expected)
+				}
+			}
+		})
+	}
+}
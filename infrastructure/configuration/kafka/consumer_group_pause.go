@@ -0,0 +1,197 @@
+package kafka
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"sync"
+)
+
+type partitionPauser struct {
+	mutex            sync.RWMutex
+	consumerGroup    sarama.ConsumerGroup
+	pausedAll        bool
+	pausedPartitions map[string]map[int32]struct{}
+	heldPartitions   map[string]map[int32]struct{}
+	changed          chan struct{}
+}
+
+func newPartitionPauser() *partitionPauser {
+	return &partitionPauser{
+		pausedPartitions: make(map[string]map[int32]struct{}),
+		heldPartitions:   make(map[string]map[int32]struct{}),
+		changed:          make(chan struct{}),
+	}
+}
+
+func (p *partitionPauser) setConsumerGroup(consumerGroup sarama.ConsumerGroup) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.consumerGroup = consumerGroup
+	if consumerGroup != nil && p.pausedAll {
+		consumerGroup.PauseAll()
+	}
+}
+
+func (p *partitionPauser) pauseAll() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pausedAll = true
+	if p.consumerGroup != nil {
+		p.consumerGroup.PauseAll()
+	}
+	p.notify()
+}
+
+func (p *partitionPauser) pause(topic string, partitions []int32) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, exists := p.pausedPartitions[topic]; !exists {
+		p.pausedPartitions[topic] = make(map[int32]struct{})
+	}
+	for _, partition := range partitions {
+		p.pausedPartitions[topic][partition] = struct{}{}
+	}
+	if p.consumerGroup != nil {
+		p.consumerGroup.Pause(map[string][]int32{topic: partitions})
+	}
+	p.notify()
+}
+
+func (p *partitionPauser) resumeAll() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pausedAll = false
+	p.pausedPartitions = make(map[string]map[int32]struct{})
+	if p.consumerGroup != nil {
+		p.consumerGroup.ResumeAll()
+		if len(p.heldPartitions) != 0 {
+			p.consumerGroup.Pause(toTopicPartitions(p.heldPartitions))
+		}
+	}
+	p.notify()
+}
+
+func (p *partitionPauser) resume(topic string, partitions []int32) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, partition := range partitions {
+		delete(p.pausedPartitions[topic], partition)
+	}
+	if len(p.pausedPartitions[topic]) == 0 {
+		delete(p.pausedPartitions, topic)
+	}
+	resumable := make([]int32, 0, len(partitions))
+	for _, partition := range partitions {
+		if _, held := p.heldPartitions[topic][partition]; !held {
+			resumable = append(resumable, partition)
+		}
+	}
+	if p.consumerGroup != nil && !p.pausedAll && len(resumable) != 0 {
+		p.consumerGroup.Resume(map[string][]int32{topic: resumable})
+	}
+	p.notify()
+}
+
+// hold pauses a partition for the abandoned handler tracker, operator pauses and resumes don't change it.
+func (p *partitionPauser) hold(topic string, partition int32) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, exists := p.heldPartitions[topic]; !exists {
+		p.heldPartitions[topic] = make(map[int32]struct{})
+	}
+	p.heldPartitions[topic][partition] = struct{}{}
+	if p.consumerGroup != nil {
+		p.consumerGroup.Pause(map[string][]int32{topic: {partition}})
+	}
+	p.notify()
+}
+
+// unhold resumes a held partition unless an operator paused it.
+func (p *partitionPauser) unhold(topic string, partition int32) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.heldPartitions[topic], partition)
+	if len(p.heldPartitions[topic]) == 0 {
+		delete(p.heldPartitions, topic)
+	}
+	if p.consumerGroup != nil && !p.isPartitionPaused(topic, partition) {
+		p.consumerGroup.Resume(map[string][]int32{topic: {partition}})
+	}
+	p.notify()
+}
+
+// pauseFetch stops fetching a partition without recording it as paused.
+func (p *partitionPauser) pauseFetch(topic string, partition int32) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.consumerGroup != nil {
+		p.consumerGroup.Pause(map[string][]int32{topic: {partition}})
+	}
+}
+
+func (p *partitionPauser) resumeFetch(topic string, partition int32) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.consumerGroup != nil && !p.isPartitionPaused(topic, partition) {
+		p.consumerGroup.Resume(map[string][]int32{topic: {partition}})
+	}
+}
+
+func (p *partitionPauser) isPaused() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.pausedAll || len(p.pausedPartitions) != 0
+}
+
+// reapply pauses a newly claimed partition again, sarama forgets paused partitions after a rebalance.
+func (p *partitionPauser) reapply(topic string, partition int32) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.consumerGroup != nil && p.isPartitionPaused(topic, partition) {
+		p.consumerGroup.Pause(map[string][]int32{topic: {partition}})
+	}
+}
+
+// waitWhilePaused holds back messages that were already fetched before the partition was paused.
+func (p *partitionPauser) waitWhilePaused(ctx context.Context, topic string, partition int32) bool {
+	for {
+		p.mutex.RLock()
+		paused := p.isPartitionPaused(topic, partition)
+		changed := p.changed
+		p.mutex.RUnlock()
+		if !paused {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (p *partitionPauser) isPartitionPaused(topic string, partition int32) bool {
+	if p.pausedAll {
+		return true
+	}
+	if _, held := p.heldPartitions[topic][partition]; held {
+		return true
+	}
+	_, exists := p.pausedPartitions[topic][partition]
+	return exists
+}
+
+func toTopicPartitions(partitionMap map[string]map[int32]struct{}) map[string][]int32 {
+	topicPartitions := make(map[string][]int32, len(partitionMap))
+	for topic, partitions := range partitionMap {
+		for partition := range partitions {
+			topicPartitions[topic] = append(topicPartitions[topic], partition)
+		}
+	}
+	return topicPartitions
+}
+
+func (p *partitionPauser) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
@@ -1,12 +1,17 @@
 package kafka
 
 import (
+	"github.com/IBM/sarama"
+	"sync"
 	"time"
 )
 
 type ConsumerGroupState struct {
-	Config                    *ConsumerGroupConfig       `json:"config"`
+	GroupId                   string                     `json:"groupId"`
+	Config                    *ConsumerGroupConfig       `json:"config,omitempty"`
+	ErrorConfig               *ConsumerGroupErrorConfig  `json:"errorConfig,omitempty"`
 	Status                    ConsumerGroupStatus        `json:"status"`
+	Paused                    bool                       `json:"paused"`
 	ConsumerGroupHandlerState *ConsumerGroupHandlerState `json:"consumerGroupHandlerState"`
 }
 type ConsumerGroupStatus string
@@ -20,8 +25,8 @@ const (
 type ConsumerGroupHandlerState struct {
 	GroupId             string                                     `json:"groupId"`
 	Status              ConsumerGroupHandlerStatus                 `json:"status"`
-	CreatedDate         time.Time                                  `json:"createdDate,omitempty"`
-	ClosedDate          time.Time                                  `json:"closedDate,omitempty"`
+	CreatedDate         time.Time                                  `json:"createdDate"`
+	ClosedDate          *time.Time                                 `json:"closedDate,omitempty"`
 	ConsumerTopicStates map[string]*ConsumerGroupHandlerTopicState `json:"consumerTopicStates"`
 }
 
@@ -34,14 +39,16 @@ const (
 )
 
 type ConsumerGroupHandlerTopicState struct {
-	Topic                string                          `json:"topic"`
-	Partition            int32                           `json:"partition"`
-	Status               ConsumerGroupHandlerTopicStatus `json:"status"`
-	CreatedDate          time.Time                       `json:"createdDate,omitempty"`
-	ListeningDate        time.Time                       `json:"listeningDate,omitempty"`
-	ClosedDate           time.Time                       `json:"closedDate,omitempty"`
-	LatestConsumedOffset int64                           `json:"latestConsumedOffset,omitempty"`
-	LatestConsumedDate   time.Time                       `json:"latestConsumedDate,omitempty"`
+	Topic                   string                          `json:"topic"`
+	Partition               int32                           `json:"partition"`
+	Status                  ConsumerGroupHandlerTopicStatus `json:"status"`
+	CreatedDate             time.Time                       `json:"createdDate"`
+	ListeningDate           *time.Time                      `json:"listeningDate,omitempty"`
+	ClosedDate              *time.Time                      `json:"closedDate,omitempty"`
+	InitialOffset           int64                           `json:"initialOffset"`
+	LatestConsumedOffset    int64                           `json:"latestConsumedOffset"`
+	LatestConsumedDate      *time.Time                      `json:"latestConsumedDate,omitempty"`
+	LatestConsumedTimestamp *time.Time                      `json:"latestConsumedTimestamp,omitempty"`
 }
 
 type ConsumerGroupHandlerTopicStatus string
@@ -53,3 +60,96 @@ const (
 	ConsumerGroupHandlerTopicClosed     ConsumerGroupHandlerTopicStatus = "CLOSED"
 	ConsumerGroupHandlerTopicNewMessage ConsumerGroupHandlerTopicStatus = "NEW_MESSAGE"
 )
+
+type consumerGroupHandlerStateTracker struct {
+	mutex sync.RWMutex
+	state *ConsumerGroupHandlerState
+}
+
+func newConsumerGroupHandlerStateTracker(groupId string) *consumerGroupHandlerStateTracker {
+	return &consumerGroupHandlerStateTracker{
+		state: &ConsumerGroupHandlerState{
+			GroupId:             groupId,
+			Status:              ConsumerGroupHandlerCreated,
+			CreatedDate:         time.Now(),
+			ConsumerTopicStates: make(map[string]*ConsumerGroupHandlerTopicState),
+		},
+	}
+}
+
+func (tracker *consumerGroupHandlerStateTracker) started(claims map[string][]int32) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			tracker.state.ConsumerTopicStates[getTopicPartitionKey(topic, partition)] = &ConsumerGroupHandlerTopicState{
+				Topic:       topic,
+				Partition:   partition,
+				Status:      ConsumerGroupHandlerTopicCreated,
+				CreatedDate: time.Now(),
+			}
+		}
+	}
+	tracker.state.Status = ConsumerGroupHandlerStarted
+}
+
+func (tracker *consumerGroupHandlerStateTracker) closed() {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	closedDate := time.Now()
+	for _, state := range tracker.state.ConsumerTopicStates {
+		state.Status = ConsumerGroupHandlerTopicClosed
+		state.ClosedDate = &closedDate
+	}
+	tracker.state.Status = ConsumerGroupHandlerClosed
+	tracker.state.ClosedDate = &closedDate
+}
+
+func (tracker *consumerGroupHandlerStateTracker) updateTopicState(topic string, partition int32, update func(state *ConsumerGroupHandlerTopicState)) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if state, exists := tracker.state.ConsumerTopicStates[getTopicPartitionKey(topic, partition)]; exists {
+		update(state)
+	}
+}
+
+func (tracker *consumerGroupHandlerStateTracker) listening(claim sarama.ConsumerGroupClaim) {
+	tracker.updateTopicState(claim.Topic(), claim.Partition(), func(state *ConsumerGroupHandlerTopicState) {
+		state.Status = ConsumerGroupHandlerTopicListening
+		listeningDate := time.Now()
+		state.ListeningDate = &listeningDate
+		state.InitialOffset = claim.InitialOffset()
+	})
+}
+
+func (tracker *consumerGroupHandlerStateTracker) consumed(message *sarama.ConsumerMessage, status ConsumerGroupHandlerTopicStatus) {
+	tracker.updateTopicState(message.Topic, message.Partition, func(state *ConsumerGroupHandlerTopicState) {
+		state.Status = status
+		state.LatestConsumedOffset = message.Offset
+		consumedDate := time.Now()
+		state.LatestConsumedDate = &consumedDate
+		state.LatestConsumedTimestamp = nil
+		if !message.Timestamp.IsZero() {
+			timestamp := message.Timestamp
+			state.LatestConsumedTimestamp = &timestamp
+		}
+	})
+}
+
+func (tracker *consumerGroupHandlerStateTracker) topicClosed(topic string, partition int32) {
+	tracker.updateTopicState(topic, partition, func(state *ConsumerGroupHandlerTopicState) {
+		state.Status = ConsumerGroupHandlerTopicClosed
+	})
+}
+
+func (tracker *consumerGroupHandlerStateTracker) Snapshot() *ConsumerGroupHandlerState {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+	snapshot := *tracker.state
+	snapshot.ConsumerTopicStates = make(map[string]*ConsumerGroupHandlerTopicState, len(tracker.state.ConsumerTopicStates))
+	for key, state := range tracker.state.ConsumerTopicStates {
+		topicState := *state
+		snapshot.ConsumerTopicStates[key] = &topicState
+	}
+	return &snapshot
+}
@@ -0,0 +1,148 @@
+package kafka
+
+import (
+	"errors"
+	"github.com/IBM/sarama"
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
+	"sync"
+)
+
+// maxPendingTransactionalOffsets is the number of processed messages of a partition whose offset can wait for the next transaction.
+const maxPendingTransactionalOffsets = 100
+
+// transactionalProducer publishes retry and error messages and commits the consumed offsets in one kafka transaction.
+// Offsets of processed messages are batched and committed with the next hand off or when the partition is flushed.
+type transactionalProducer struct {
+	mutex          sync.Mutex
+	clusterConfig  *ClusterConfig
+	groupConfig    *ConsumerGroupConfig
+	producer       sarama.SyncProducer
+	pendingOffsets map[string]*pendingTransactionalOffset
+}
+
+type pendingTransactionalOffset struct {
+	message *sarama.ConsumerMessage
+	count   int
+}
+
+func newTransactionalProducer(clusterConfig *ClusterConfig, consumerGroupConfig *ConsumerGroupConfig) *transactionalProducer {
+	return &transactionalProducer{
+		clusterConfig:  clusterConfig,
+		groupConfig:    consumerGroupConfig,
+		pendingOffsets: make(map[string]*pendingTransactionalOffset),
+	}
+}
+
+func (p *transactionalProducer) open() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.producer != nil {
+		return nil
+	}
+	saramaConfig, err := getSaramaTransactionalProducerConfig(p.clusterConfig, p.groupConfig)
+	if err != nil {
+		return err
+	}
+	producer, err := sarama.NewSyncProducer(p.clusterConfig.GetBrokers(), saramaConfig)
+	if err != nil {
+		return err
+	}
+	p.producer = producer
+	return nil
+}
+
+// Close drops the offsets that were not committed, the messages are consumed again by the next owner of the partition.
+func (p *transactionalProducer) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pendingOffsets = make(map[string]*pendingTransactionalOffset)
+	if p.producer == nil {
+		return nil
+	}
+	err := p.producer.Close()
+	p.producer = nil
+	return err
+}
+
+func (p *transactionalProducer) run(message *ConsumerMessage, produce func(producer SyncProducer) error) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err := p.runTxn(message.ConsumerMessage, produce); err != nil {
+		return err
+	}
+	delete(p.pendingOffsets, getTopicPartitionKey(message.Topic, message.Partition))
+	return nil
+}
+
+// markOffset keeps the offset for a later transaction and reports whether the partition should be flushed now.
+func (p *transactionalProducer) markOffset(message *ConsumerMessage) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := getTopicPartitionKey(message.Topic, message.Partition)
+	pending, exists := p.pendingOffsets[key]
+	if !exists {
+		pending = &pendingTransactionalOffset{}
+		p.pendingOffsets[key] = pending
+	}
+	pending.message = message.ConsumerMessage
+	pending.count++
+	return pending.count >= maxPendingTransactionalOffsets
+}
+
+func (p *transactionalProducer) flush(topic string, partition int32) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := getTopicPartitionKey(topic, partition)
+	pending, exists := p.pendingOffsets[key]
+	if !exists {
+		return nil
+	}
+	if err := p.runTxn(pending.message, func(SyncProducer) error {
+		return nil
+	}); err != nil {
+		return err
+	}
+	delete(p.pendingOffsets, key)
+	return nil
+}
+
+// dropPendingOffsets forgets the offsets of the claimed partitions once the session ends, the final flush is already attempted by then
+// and a stale offset must not be committed over the commit of the next owner when the partition comes back.
+func (p *transactionalProducer) dropPendingOffsets(claims map[string][]int32) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			delete(p.pendingOffsets, getTopicPartitionKey(topic, partition))
+		}
+	}
+}
+
+func (p *transactionalProducer) runTxn(message *sarama.ConsumerMessage, produce func(producer SyncProducer) error) error {
+	if p.producer == nil {
+		return custom_error.NewErrWithArgs("transactional producer is closed, group: %s", p.groupConfig.GroupId)
+	}
+	if err := p.producer.BeginTxn(); err != nil {
+		return err
+	}
+	if err := produce(p.producer); err != nil {
+		return p.abort(err)
+	}
+	if err := p.producer.AddMessageToTxn(message, p.groupConfig.GroupId, nil); err != nil {
+		return p.abort(err)
+	}
+	if err := p.producer.CommitTxn(); err != nil {
+		return p.abort(err)
+	}
+	return nil
+}
+
+func (p *transactionalProducer) abort(err error) error {
+	if p.producer.TxnStatus()&sarama.ProducerTxnFlagFatalError != 0 {
+		return errors.Join(err, custom_error.NewErrWithArgs("transactional producer is in fatal state, group: %s", p.groupConfig.GroupId))
+	}
+	if abortErr := p.producer.AbortTxn(); abortErr != nil {
+		return errors.Join(err, abortErr)
+	}
+	return err
+}
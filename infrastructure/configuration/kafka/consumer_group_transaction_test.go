@@ -0,0 +1,229 @@
+package kafka
+
+import (
+	"errors"
+	"github.com/IBM/sarama"
+	"slices"
+	"testing"
+)
+
+// fakeTxnProducer records the transaction calls, the offsets committed in a transaction and fails the configured step.
+type fakeTxnProducer struct {
+	sarama.SyncProducer
+	commitErr error
+	status    sarama.ProducerTxnStatusFlag
+	calls     []string
+	committed []int64
+}
+
+func (p *fakeTxnProducer) BeginTxn() error {
+	p.calls = append(p.calls, "begin")
+	return nil
+}
+
+func (p *fakeTxnProducer) AddMessageToTxn(message *sarama.ConsumerMessage, _ string, _ *string) error {
+	p.calls = append(p.calls, "offset")
+	p.committed = append(p.committed, message.Offset)
+	return nil
+}
+
+func (p *fakeTxnProducer) CommitTxn() error {
+	p.calls = append(p.calls, "commit")
+	return p.commitErr
+}
+
+func (p *fakeTxnProducer) AbortTxn() error {
+	p.calls = append(p.calls, "abort")
+	return nil
+}
+
+func (p *fakeTxnProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return p.status
+}
+
+func newTestTransactionalProducer(producer sarama.SyncProducer) *transactionalProducer {
+	p := newTransactionalProducer(&ClusterConfig{}, &ConsumerGroupConfig{GroupId: "group"})
+	p.producer = producer
+	return p
+}
+
+func TestTransactionalProducer_MarkOffset(t *testing.T) {
+	tests := []struct {
+		name          string
+		marks         int
+		expectedFlush bool
+	}{
+		{name: "single offset", marks: 1, expectedFlush: false},
+		{name: "below the limit", marks: maxPendingTransactionalOffsets - 1, expectedFlush: false},
+		{name: "at the limit", marks: maxPendingTransactionalOffsets, expectedFlush: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestTransactionalProducer(&fakeTxnProducer{})
+			var flush bool
+			for i := 0; i < test.marks; i++ {
+				flush = p.markOffset(newTestConsumerMessage("topic", "key", int64(i)))
+			}
+			if flush != test.expectedFlush {
+				t.Errorf("flush = %t, expected %t", flush, test.expectedFlush)
+			}
+		})
+	}
+}
+
+func TestTransactionalProducer_Flush(t *testing.T) {
+	tests := []struct {
+		name              string
+		marked            []int64
+		commitErr         error
+		status            sarama.ProducerTxnStatusFlag
+		expectedErr       bool
+		expectedCalls     []string
+		expectedCommitted []int64
+		expectedPending   bool
+	}{
+		{
+			name:          "nothing pending",
+			expectedCalls: []string{},
+		},
+		{
+			name:              "latest offset is committed",
+			marked:            []int64{1, 2, 3},
+			expectedCalls:     []string{"begin", "offset", "commit"},
+			expectedCommitted: []int64{3},
+		},
+		{
+			name:              "failed commit is aborted and kept",
+			marked:            []int64{1, 2},
+			commitErr:         errors.New("commit failed"),
+			expectedErr:       true,
+			expectedCalls:     []string{"begin", "offset", "commit", "abort"},
+			expectedCommitted: []int64{2},
+			expectedPending:   true,
+		},
+		{
+			name:              "fatal state is not aborted",
+			marked:            []int64{1},
+			commitErr:         errors.New("commit failed"),
+			status:            sarama.ProducerTxnFlagFatalError,
+			expectedErr:       true,
+			expectedCalls:     []string{"begin", "offset", "commit"},
+			expectedCommitted: []int64{1},
+			expectedPending:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			producer := &fakeTxnProducer{commitErr: test.commitErr, status: test.status, calls: []string{}}
+			p := newTestTransactionalProducer(producer)
+			for _, offset := range test.marked {
+				p.markOffset(newTestConsumerMessage("topic", "key", offset))
+			}
+
+			err := p.flush("topic", 0)
+
+			if (err != nil) != test.expectedErr {
+				t.Errorf("err = %v, expected error %t", err, test.expectedErr)
+			}
+			if !slices.Equal(producer.calls, test.expectedCalls) {
+				t.Errorf("calls = %v, expected %v", producer.calls, test.expectedCalls)
+			}
+			if !slices.Equal(producer.committed, test.expectedCommitted) {
+				t.Errorf("committed offsets = %v, expected %v", producer.committed, test.expectedCommitted)
+			}
+			if _, pending := p.pendingOffsets[getTopicPartitionKey("topic", 0)]; pending != test.expectedPending {
+				t.Errorf("pending = %t, expected %t", pending, test.expectedPending)
+			}
+		})
+	}
+}
+
+func TestTransactionalProducer_Run(t *testing.T) {
+	tests := []struct {
+		name              string
+		produceErr        error
+		expectedCalls     []string
+		expectedCommitted []int64
+		expectedPending   bool
+	}{
+		{
+			name:              "hand off commits the message offset",
+			expectedCalls:     []string{"begin", "offset", "commit"},
+			expectedCommitted: []int64{5},
+			expectedPending:   false,
+		},
+		{
+			name:            "failed hand off is aborted and keeps the pending offsets",
+			produceErr:      errors.New("produce failed"),
+			expectedCalls:   []string{"begin", "abort"},
+			expectedPending: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			producer := &fakeTxnProducer{}
+			p := newTestTransactionalProducer(producer)
+			p.markOffset(newTestConsumerMessage("topic", "key", 4))
+
+			err := p.run(newTestConsumerMessage("topic", "key", 5), func(SyncProducer) error {
+				return test.produceErr
+			})
+
+			if !errors.Is(err, test.produceErr) {
+				t.Errorf("err = %v, expected %v", err, test.produceErr)
+			}
+			if !slices.Equal(producer.calls, test.expectedCalls) {
+				t.Errorf("calls = %v, expected %v", producer.calls, test.expectedCalls)
+			}
+			if !slices.Equal(producer.committed, test.expectedCommitted) {
+				t.Errorf("committed offsets = %v, expected %v", producer.committed, test.expectedCommitted)
+			}
+			if _, pending := p.pendingOffsets[getTopicPartitionKey("topic", 0)]; pending != test.expectedPending {
+				t.Errorf("pending = %t, expected %t", pending, test.expectedPending)
+			}
+		})
+	}
+}
+
+func TestTransactionalProducer_DropPendingOffsets(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   map[string][]int32
+		expected []int32
+	}{
+		{name: "no claims", claims: map[string][]int32{}, expected: []int32{0, 1}},
+		{name: "one partition claimed", claims: map[string][]int32{"topic": {1}}, expected: []int32{0}},
+		{name: "all partitions claimed", claims: map[string][]int32{"topic": {0, 1}}, expected: []int32{}},
+		{name: "other topic claimed", claims: map[string][]int32{"other": {0, 1}}, expected: []int32{0, 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestTransactionalProducer(&fakeTxnProducer{})
+			for _, partition := range []int32{0, 1} {
+				message := newTestConsumerMessage("topic", "key", 1)
+				message.Partition = partition
+				p.markOffset(message)
+			}
+
+			p.dropPendingOffsets(test.claims)
+
+			pending := make([]int32, 0)
+			for _, partition := range []int32{0, 1} {
+				if _, exists := p.pendingOffsets[getTopicPartitionKey("topic", partition)]; exists {
+					pending = append(pending, partition)
+				}
+			}
+			if !slices.Equal(pending, test.expected) {
+				t.Errorf("pending partitions = %v, expected %v", pending, test.expected)
+			}
+		})
+	}
+}
+
+func TestTransactionalProducer_Closed(t *testing.T) {
+	p := newTestTransactionalProducer(nil)
+	p.markOffset(newTestConsumerMessage("topic", "key", 1))
+	if err := p.flush("topic", 0); err == nil {
+		t.Error("flush of a closed producer is expected to fail")
+	}
+}
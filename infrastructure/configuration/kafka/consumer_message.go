@@ -3,8 +3,11 @@ package kafka
 import (
 	"fmt"
 	"github.com/IBM/sarama"
+	"os"
+	"presentation-advert-consumer/infrastructure/configuration/custom_json"
 	"presentation-advert-consumer/util"
 	"strconv"
+	"time"
 )
 
 type ConsumerMessage struct {
@@ -12,10 +15,69 @@ type ConsumerMessage struct {
 	GroupId string
 }
 
+type ErrorAttempt struct {
+	Timestamp time.Time `json:"timestamp"`
+	Topic     string    `json:"topic"`
+	Partition int32     `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Host      string    `json:"host"`
+	Error     string    `json:"error"`
+}
+
+const (
+	maxErrorHistorySize    = 10
+	maxErrorAttemptMessage = 1024
+)
+
+var hostname, _ = os.Hostname()
+
+type OriginalCoordinates struct {
+	Topic            string    `json:"topic"`
+	Partition        int32     `json:"partition"`
+	Offset           int64     `json:"offset"`
+	Timestamp        time.Time `json:"timestamp"`
+	FirstFailureTime time.Time `json:"firstFailureTime"`
+}
+
+func (message *ConsumerMessage) GetErrorHistory() []ErrorAttempt {
+	return getErrorHistory(message)
+}
+
+// GetOriginalCoordinates returns where the message was first consumed, falling back to its own coordinates before the first failure.
+func (message *ConsumerMessage) GetOriginalCoordinates() *OriginalCoordinates {
+	return getOriginalCoordinates(message)
+}
+
+// cloneConsumerMessage copies the message and its headers, so a consumer abandoned after a timeout does not share them with the routing.
+func cloneConsumerMessage(message *ConsumerMessage) *ConsumerMessage {
+	saramaMessage := *message.ConsumerMessage
+	saramaMessage.Headers = make([]*sarama.RecordHeader, 0, len(message.Headers))
+	for _, header := range message.Headers {
+		copied := *header
+		saramaMessage.Headers = append(saramaMessage.Headers, &copied)
+	}
+	return &ConsumerMessage{ConsumerMessage: &saramaMessage, GroupId: message.GroupId}
+}
+
 func getRetriedCount(message *ConsumerMessage) int {
 	return getHeaderIntValue(message, RetryTopicCountKey) + 1
 }
 
+func getRetrySequence(message *ConsumerMessage) int64 {
+	return getHeaderInt64Value(message, RetrySequenceKey)
+}
+
+func setRetrySequence(message *ConsumerMessage, sequence int64) {
+	value := util.ToByte(fmt.Sprint(sequence))
+	for _, header := range message.Headers {
+		if string(header.Key) == RetrySequenceKey.String() {
+			header.Value = value
+			return
+		}
+	}
+	message.Headers = append(message.Headers, &sarama.RecordHeader{Key: util.ToByte(RetrySequenceKey.String()), Value: value})
+}
+
 func getErrorCount(message *ConsumerMessage) int {
 	return getHeaderIntValue(message, ErrorTopicCountKey)
 }
@@ -24,6 +86,79 @@ func getErrorMessage(message *ConsumerMessage) string {
 	return getHeaderStrValue(message, ErrorMessageKey)
 }
 
+func getRetryAfter(message *sarama.ConsumerMessage) time.Time {
+	value := getHeaderValue(&ConsumerMessage{ConsumerMessage: message}, RetryAfterKey)
+	if value == nil {
+		return time.Time{}
+	}
+	retryAfter, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(retryAfter)
+}
+
+func getErrorHistory(message *ConsumerMessage) []ErrorAttempt {
+	value := getHeaderValue(message, ErrorHistoryKey)
+	if value == nil {
+		return nil
+	}
+	var history []ErrorAttempt
+	if err := custom_json.Unmarshal(value, &history); err != nil {
+		return nil
+	}
+	return history
+}
+
+// appendErrorHistory adds the current failure to the history and keeps only the latest attempts.
+func appendErrorHistory(message *ConsumerMessage, errorMessage string) []byte {
+	if len(errorMessage) > maxErrorAttemptMessage {
+		errorMessage = errorMessage[:maxErrorAttemptMessage]
+	}
+	history := append(getErrorHistory(message), ErrorAttempt{
+		Timestamp: time.Now(),
+		Topic:     message.Topic,
+		Partition: message.Partition,
+		Offset:    message.Offset,
+		Host:      hostname,
+		Error:     errorMessage,
+	})
+	if len(history) > maxErrorHistorySize {
+		history = history[len(history)-maxErrorHistorySize:]
+	}
+	value, err := custom_json.Marshal(history)
+	if err != nil {
+		return getHeaderValue(message, ErrorHistoryKey)
+	}
+	return value
+}
+
+func hasOriginalCoordinates(message *ConsumerMessage) bool {
+	return getHeaderValue(message, OriginalTopicKey) != nil
+}
+
+func getOriginalCoordinates(message *ConsumerMessage) *OriginalCoordinates {
+	if !hasOriginalCoordinates(message) {
+		return &OriginalCoordinates{
+			Topic:     message.Topic,
+			Partition: message.Partition,
+			Offset:    message.Offset,
+			Timestamp: message.Timestamp,
+		}
+	}
+	return &OriginalCoordinates{
+		Topic:            getHeaderStrValue(message, OriginalTopicKey),
+		Partition:        int32(getHeaderInt64Value(message, OriginalPartitionKey)),
+		Offset:           getHeaderInt64Value(message, OriginalOffsetKey),
+		Timestamp:        time.UnixMilli(getHeaderInt64Value(message, OriginalTimestampKey)),
+		FirstFailureTime: time.UnixMilli(getHeaderInt64Value(message, FirstFailureTimeKey)),
+	}
+}
+
+func isPermanentError(message *ConsumerMessage) bool {
+	return getHeaderStrValue(message, PermanentErrorKey) == "true"
+}
+
 func getTargetTopic(message *ConsumerMessage) string {
 	return getHeaderStrValue(message, TargetTopicKey)
 }
@@ -37,6 +172,15 @@ func getHeaderIntValue(message *ConsumerMessage, key ContextKey) int {
 	return count
 }
 
+func getHeaderInt64Value(message *ConsumerMessage, key ContextKey) int64 {
+	value := getHeaderValue(message, key)
+	if value == nil {
+		return 0
+	}
+	result, _ := strconv.ParseInt(string(value), 10, 64)
+	return result
+}
+
 func getHeaderStrValue(message *ConsumerMessage, key ContextKey) string {
 	value := getHeaderValue(message, key)
 	if value == nil {
@@ -54,40 +198,51 @@ func getHeaderValue(message *ConsumerMessage, key ContextKey) []byte {
 	return nil
 }
 
-func headersForRetry(message *ConsumerMessage, errorMessage string) []sarama.RecordHeader {
-	messageHeaderMap := make(map[ContextKey][]byte)
-	for _, header := range message.Headers {
-		messageHeaderMap[ContextKey(header.Key)] = header.Value
-	}
-	messageHeaderMap[ErrorMessageKey] = util.ToByte(errorMessage)
-	messageHeaderMap[RetryTopicCountKey] = util.ToByte("0")
-
-	return mapToHeaderArray(messageHeaderMap)
-}
-
 func headersForError(message *ConsumerMessage, errorMessage string) []sarama.RecordHeader {
 	messageHeaderMap := make(map[ContextKey][]byte)
 	for _, header := range message.Headers {
 		messageHeaderMap[ContextKey(header.Key)] = header.Value
 	}
 	messageHeaderMap[ErrorMessageKey] = util.ToByte(errorMessage)
+	messageHeaderMap[ErrorHistoryKey] = appendErrorHistory(message, errorMessage)
+	setOriginalCoordinates(messageHeaderMap, message)
 	messageHeaderMap[ErrorTopicCountKey] = util.ToByte("0")
+	delete(messageHeaderMap, RetrySequenceKey)
 
 	return mapToHeaderArray(messageHeaderMap)
 }
 
-func headersFromRetryToRetry(message *ConsumerMessage, errorMessage string, retriedCount int) []sarama.RecordHeader {
+func headersToRetryTier(message *ConsumerMessage, errorMessage string, retriedCount int, retryDelay time.Duration) []sarama.RecordHeader {
 	messageHeaderMap := make(map[ContextKey][]byte)
 	for _, header := range message.Headers {
 		messageHeaderMap[ContextKey(header.Key)] = header.Value
 	}
 	messageHeaderMap[ErrorMessageKey] = util.ToByte(errorMessage)
+	messageHeaderMap[ErrorHistoryKey] = appendErrorHistory(message, errorMessage)
+	setOriginalCoordinates(messageHeaderMap, message)
 	messageHeaderMap[RetryTopicCountKey] = util.ToByte(fmt.Sprint(retriedCount))
+	setRetryAfter(messageHeaderMap, retryDelay)
 
 	return mapToHeaderArray(messageHeaderMap)
 }
 
 func headersFromRetryToError(message *ConsumerMessage, errorMessage string) []sarama.RecordHeader {
+	return mapToHeaderArray(errorTopicHeaderMap(message, errorMessage, message.Topic))
+}
+
+// headersForPermanentError sets no target topic, the error consumer parks the message instead of requeueing it.
+func headersForPermanentError(message *ConsumerMessage, errorMessage string) []sarama.RecordHeader {
+	messageHeaderMap := errorTopicHeaderMap(message, errorMessage, "")
+	messageHeaderMap[PermanentErrorKey] = util.ToByte("true")
+
+	return mapToHeaderArray(messageHeaderMap)
+}
+
+func headersForTimeoutError(message *ConsumerMessage, errorMessage string, targetTopic string) []sarama.RecordHeader {
+	return mapToHeaderArray(errorTopicHeaderMap(message, errorMessage, targetTopic))
+}
+
+func errorTopicHeaderMap(message *ConsumerMessage, errorMessage string, targetTopic string) map[ContextKey][]byte {
 	messageHeaderMap := make(map[ContextKey][]byte)
 	for _, header := range message.Headers {
 		messageHeaderMap[ContextKey(header.Key)] = header.Value
@@ -95,8 +250,28 @@ func headersFromRetryToError(message *ConsumerMessage, errorMessage string) []sa
 	errorCount := getErrorCount(message)
 	messageHeaderMap[ErrorTopicCountKey] = util.ToByte(fmt.Sprint(errorCount + 1))
 	messageHeaderMap[ErrorMessageKey] = util.ToByte(errorMessage)
-	messageHeaderMap[TargetTopicKey] = util.ToByte(message.Topic)
+	messageHeaderMap[ErrorHistoryKey] = appendErrorHistory(message, errorMessage)
+	setOriginalCoordinates(messageHeaderMap, message)
+	if targetTopic != "" {
+		messageHeaderMap[TargetTopicKey] = util.ToByte(targetTopic)
+	} else {
+		delete(messageHeaderMap, TargetTopicKey)
+	}
 	delete(messageHeaderMap, RetryTopicCountKey)
+	delete(messageHeaderMap, RetryAfterKey)
+	delete(messageHeaderMap, RetrySequenceKey)
+	return messageHeaderMap
+}
+
+// headersForOrderedRetry queues a message behind its key in the retry tier without counting it as a failure.
+func headersForOrderedRetry(message *ConsumerMessage, retriedCount int, retryDelay time.Duration) []sarama.RecordHeader {
+	messageHeaderMap := make(map[ContextKey][]byte)
+	for _, header := range message.Headers {
+		messageHeaderMap[ContextKey(header.Key)] = header.Value
+	}
+	setOriginalCoordinates(messageHeaderMap, message)
+	messageHeaderMap[RetryTopicCountKey] = util.ToByte(fmt.Sprint(retriedCount))
+	setRetryAfter(messageHeaderMap, retryDelay)
 
 	return mapToHeaderArray(messageHeaderMap)
 }
@@ -107,10 +282,40 @@ func headersFromErrorToRetry(message *ConsumerMessage) []sarama.RecordHeader {
 		messageHeaderMap[ContextKey(header.Key)] = header.Value
 	}
 	delete(messageHeaderMap, RetryTopicCountKey)
+	delete(messageHeaderMap, RetryAfterKey)
+	delete(messageHeaderMap, RetrySequenceKey)
 
 	return mapToHeaderArray(messageHeaderMap)
 }
 
+func headersForParkingLot(message *ConsumerMessage) []sarama.RecordHeader {
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers))
+	for _, header := range message.Headers {
+		headers = append(headers, *header)
+	}
+	return headers
+}
+
+// setOriginalCoordinates stamps the origin on the first failure only, later hops keep the existing values.
+func setOriginalCoordinates(messageHeaderMap map[ContextKey][]byte, message *ConsumerMessage) {
+	if _, exists := messageHeaderMap[OriginalTopicKey]; exists {
+		return
+	}
+	messageHeaderMap[OriginalTopicKey] = util.ToByte(message.Topic)
+	messageHeaderMap[OriginalPartitionKey] = util.ToByte(fmt.Sprint(message.Partition))
+	messageHeaderMap[OriginalOffsetKey] = util.ToByte(fmt.Sprint(message.Offset))
+	messageHeaderMap[OriginalTimestampKey] = util.ToByte(fmt.Sprint(message.Timestamp.UnixMilli()))
+	messageHeaderMap[FirstFailureTimeKey] = util.ToByte(fmt.Sprint(time.Now().UnixMilli()))
+}
+
+func setRetryAfter(messageHeaderMap map[ContextKey][]byte, retryDelay time.Duration) {
+	if retryDelay <= 0 {
+		delete(messageHeaderMap, RetryAfterKey)
+		return
+	}
+	messageHeaderMap[RetryAfterKey] = util.ToByte(fmt.Sprint(time.Now().Add(retryDelay).UnixMilli()))
+}
+
 func mapToHeaderArray(messageHeaderMap map[ContextKey][]byte) []sarama.RecordHeader {
 	headers := make([]sarama.RecordHeader, 0)
 	for key, bytes := range messageHeaderMap {
@@ -127,5 +332,5 @@ func isMainTopic(message *ConsumerMessage, consumerTopicConfig *ConsumerGroupCon
 }
 
 func isRetryTopic(message *ConsumerMessage, consumerTopicConfig *ConsumerGroupConfig) bool {
-	return message.Topic == consumerTopicConfig.Retry
+	return consumerTopicConfig.GetRetryTierIndex(message.Topic) >= 0
 }
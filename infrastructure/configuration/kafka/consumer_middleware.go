@@ -0,0 +1,107 @@
+package kafka
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"presentation-advert-consumer/util"
+	"time"
+)
+
+type ConsumerMiddleware func(Consumer) Consumer
+
+type ConsumerFunc func(ctx context.Context, message *ConsumerMessage) error
+
+func (f ConsumerFunc) Consume(ctx context.Context, message *ConsumerMessage) error {
+	return f(ctx, message)
+}
+
+func DefaultConsumerMiddlewares() []ConsumerMiddleware {
+	return []ConsumerMiddleware{
+		RecoveryConsumerMiddleware,
+		CorrelationIdConsumerMiddleware,
+		TraceContextConsumerMiddleware,
+		LoggingConsumerMiddleware,
+		MetricsConsumerMiddleware,
+	}
+}
+
+// chainConsumerMiddlewares wraps the consumer so that the first middleware runs outermost.
+func chainConsumerMiddlewares(consumer Consumer, middlewares ...ConsumerMiddleware) Consumer {
+	if consumer == nil {
+		return nil
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		consumer = middlewares[i](consumer)
+	}
+	return consumer
+}
+
+func LoggingConsumerMiddleware(next Consumer) Consumer {
+	return ConsumerFunc(func(ctx context.Context, message *ConsumerMessage) error {
+		original := getOriginalCoordinates(message)
+		log.Infof("Consumed message, group: %s, topic: %s, partition: %d, offset: %d, key: %s, original topic: %s, partition: %d, offset: %d",
+			message.GroupId, message.Topic, message.Partition, message.Offset, string(message.Key), original.Topic, original.Partition, original.Offset)
+		err := next.Consume(ctx, message)
+		if err != nil {
+			log.Errorf("An error occurred when consuming message, group: %s, topic: %s, partition: %d, offset: %d, key: %s, original topic: %s, partition: %d, offset: %d, err: %s",
+				message.GroupId, message.Topic, message.Partition, message.Offset, string(message.Key), original.Topic, original.Partition, original.Offset, err.Error())
+		}
+		return err
+	})
+}
+
+func MetricsConsumerMiddleware(next Consumer) Consumer {
+	return ConsumerFunc(func(ctx context.Context, message *ConsumerMessage) error {
+		startTime := time.Now()
+		err := next.Consume(ctx, message)
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		consumerMessageDuration.WithLabelValues(message.GroupId, message.Topic, result).Observe(time.Since(startTime).Seconds())
+		return err
+	})
+}
+
+func RecoveryConsumerMiddleware(next Consumer) Consumer {
+	return ConsumerFunc(func(ctx context.Context, message *ConsumerMessage) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				panicErr := recoverConsumerPanic(message.GroupId, message.Topic, r)
+				log.Errorf("Consumer panicked, topic: %s, partition: %d, offset: %d, err: %s, stack: %s", message.Topic, message.Partition, message.Offset, panicErr.Error(), panicErr.Stack)
+				err = panicErr
+			}
+		}()
+		return next.Consume(ctx, message)
+	})
+}
+
+// CorrelationIdConsumerMiddleware puts the message correlation id into the context, generating one when the header is missing.
+func CorrelationIdConsumerMiddleware(next Consumer) Consumer {
+	return ConsumerFunc(func(ctx context.Context, message *ConsumerMessage) error {
+		if correlationId := getHeaderStrValue(message, CorrelationIdKey); correlationId != "" && GetHeaderFromContext[string](ctx, CorrelationIdKey) == "" {
+			ctx = AddHeaderToContext(ctx, CorrelationIdKey, correlationId)
+		}
+		ctx = AddCorrelationIdToContextIfDoesNotExists(ctx)
+		if getHeaderValue(message, CorrelationIdKey) == nil {
+			message.Headers = append(message.Headers, &sarama.RecordHeader{
+				Key:   util.ToByte(CorrelationIdKey.String()),
+				Value: util.ToByte(GetHeaderFromContext[string](ctx, CorrelationIdKey)),
+			})
+		}
+		return next.Consume(ctx, message)
+	})
+}
+
+// TraceContextConsumerMiddleware puts the trace context and source service headers into the context so producers can forward them.
+func TraceContextConsumerMiddleware(next Consumer) Consumer {
+	return ConsumerFunc(func(ctx context.Context, message *ConsumerMessage) error {
+		for _, key := range []ContextKey{TraceParentKey, TraceStateKey, SourceServiceKey} {
+			if value := getHeaderStrValue(message, key); value != "" && GetHeaderFromContext[string](ctx, key) == "" {
+				ctx = AddHeaderToContext(ctx, key, value)
+			}
+		}
+		return next.Consume(ctx, message)
+	})
+}
@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"github.com/IBM/sarama"
+	"presentation-advert-consumer/util"
+	"runtime/debug"
+)
+
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func newPanicError(value any) *PanicError {
+	return &PanicError{
+		Value: value,
+		Stack: debug.Stack(),
+	}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("consumer panicked: %v", e.Value)
+}
+
+func recoverConsumerPanic(groupId string, topic string, recovered any) *PanicError {
+	consumerPanicCounter.WithLabelValues(groupId, topic).Inc()
+	return newPanicError(recovered)
+}
+
+// withPanicStack keeps the X-PanicStack header in line with the latest failure of the message.
+func withPanicStack(headers []sarama.RecordHeader, err error) []sarama.RecordHeader {
+	result := make([]sarama.RecordHeader, 0, len(headers)+1)
+	for _, header := range headers {
+		if ContextKey(header.Key) != PanicStackKey {
+			result = append(result, header)
+		}
+	}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		result = append(result, sarama.RecordHeader{
+			Key:   util.ToByte(PanicStackKey.String()),
+			Value: panicErr.Stack,
+		})
+	}
+	return result
+}
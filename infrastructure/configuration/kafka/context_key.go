@@ -12,4 +12,18 @@ const (
 	TargetTopicKey     ContextKey = "X-TargetTopic"
 	ErrorMessageKey    ContextKey = "X-ErrorMessage"
 	CorrelationIdKey   ContextKey = "X-CorrelationId"
+	PanicStackKey      ContextKey = "X-PanicStack"
+	RetryAfterKey      ContextKey = "X-RetryAfter"
+	ErrorHistoryKey    ContextKey = "X-ErrorHistory"
+	RetrySequenceKey   ContextKey = "X-RetrySequence"
+	PermanentErrorKey  ContextKey = "X-PermanentError"
+	SourceServiceKey   ContextKey = "X-SourceService"
+	TraceParentKey     ContextKey = "traceparent"
+	TraceStateKey      ContextKey = "tracestate"
+
+	OriginalTopicKey     ContextKey = "X-OriginalTopic"
+	OriginalPartitionKey ContextKey = "X-OriginalPartition"
+	OriginalOffsetKey    ContextKey = "X-OriginalOffset"
+	OriginalTimestampKey ContextKey = "X-OriginalTimestamp"
+	FirstFailureTimeKey  ContextKey = "X-FirstFailureTime"
 )
@@ -0,0 +1,155 @@
+package kafka
+
+import (
+	"sync"
+	"time"
+)
+
+type ErrorConsumerRunTrigger string
+
+const (
+	ErrorConsumerRunCron   ErrorConsumerRunTrigger = "cron"
+	ErrorConsumerRunManual ErrorConsumerRunTrigger = "manual"
+)
+
+type errorConsumerRunResult string
+
+const (
+	errorConsumerRunRequeued            errorConsumerRunResult = "requeued"
+	errorConsumerRunFailed              errorConsumerRunResult = "failed"
+	errorConsumerRunDroppedOverMaxCount errorConsumerRunResult = "dropped_over_max_count"
+	errorConsumerRunDroppedPermanent    errorConsumerRunResult = "dropped_permanent"
+	errorConsumerRunSkippedAsNew        errorConsumerRunResult = "skipped_as_new"
+	errorConsumerRunManuallyHandled     errorConsumerRunResult = "manually_handled"
+	errorConsumerRunDuplicate           errorConsumerRunResult = "duplicate"
+)
+
+const defaultErrorConsumerRunReportHistorySize = 10
+
+type ErrorConsumerRunReport struct {
+	Id         int64                                   `json:"id"`
+	Trigger    ErrorConsumerRunTrigger                 `json:"trigger"`
+	StartedAt  time.Time                               `json:"startedAt"`
+	FinishedAt *time.Time                              `json:"finishedAt,omitempty"`
+	Topics     map[string]*ErrorConsumerRunTopicReport `json:"topics"`
+}
+
+type ErrorConsumerRunTopicReport struct {
+	Seen                int `json:"seen"`
+	Requeued            int `json:"requeued"`
+	Failed              int `json:"failed"`
+	DroppedOverMaxCount int `json:"droppedOverMaxCount"`
+	DroppedPermanent    int `json:"droppedPermanent"`
+	SkippedAsNew        int `json:"skippedAsNew"`
+	ManuallyHandled     int `json:"manuallyHandled"`
+	Duplicate           int `json:"duplicate"`
+}
+
+func (report *ErrorConsumerRunReport) copy() *ErrorConsumerRunReport {
+	copied := *report
+	if report.FinishedAt != nil {
+		finishedAt := *report.FinishedAt
+		copied.FinishedAt = &finishedAt
+	}
+	copied.Topics = make(map[string]*ErrorConsumerRunTopicReport, len(report.Topics))
+	for topic, topicReport := range report.Topics {
+		copiedTopicReport := *topicReport
+		copied.Topics[topic] = &copiedTopicReport
+	}
+	return &copied
+}
+
+// errorConsumerRunRecorder keeps the report of the running error consumer and the last finished ones.
+type errorConsumerRunRecorder struct {
+	mutex       sync.Mutex
+	groupId     string
+	historySize int
+	nextId      int64
+	current     *ErrorConsumerRunReport
+	reports     []*ErrorConsumerRunReport
+}
+
+func newErrorConsumerRunRecorder(groupId string, historySize int) *errorConsumerRunRecorder {
+	if historySize <= 0 {
+		historySize = defaultErrorConsumerRunReportHistorySize
+	}
+	return &errorConsumerRunRecorder{
+		groupId:     groupId,
+		historySize: historySize,
+		reports:     make([]*ErrorConsumerRunReport, 0, historySize),
+	}
+}
+
+func (recorder *errorConsumerRunRecorder) start(trigger ErrorConsumerRunTrigger) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.nextId++
+	recorder.current = &ErrorConsumerRunReport{
+		Id:        recorder.nextId,
+		Trigger:   trigger,
+		StartedAt: time.Now(),
+		Topics:    make(map[string]*ErrorConsumerRunTopicReport),
+	}
+	errorConsumerRunCounter.WithLabelValues(recorder.groupId, string(trigger)).Inc()
+}
+
+func (recorder *errorConsumerRunRecorder) record(topic string, result errorConsumerRunResult) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if recorder.current == nil {
+		return
+	}
+	topicReport, exists := recorder.current.Topics[topic]
+	if !exists {
+		topicReport = &ErrorConsumerRunTopicReport{}
+		recorder.current.Topics[topic] = topicReport
+	}
+	topicReport.Seen++
+	switch result {
+	case errorConsumerRunRequeued:
+		topicReport.Requeued++
+	case errorConsumerRunFailed:
+		topicReport.Failed++
+	case errorConsumerRunDroppedOverMaxCount:
+		topicReport.DroppedOverMaxCount++
+	case errorConsumerRunDroppedPermanent:
+		topicReport.DroppedPermanent++
+	case errorConsumerRunSkippedAsNew:
+		topicReport.SkippedAsNew++
+	case errorConsumerRunManuallyHandled:
+		topicReport.ManuallyHandled++
+	case errorConsumerRunDuplicate:
+		topicReport.Duplicate++
+	}
+	errorConsumerRunMessageCounter.WithLabelValues(recorder.groupId, topic, string(result)).Inc()
+}
+
+func (recorder *errorConsumerRunRecorder) finish() {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if recorder.current == nil {
+		return
+	}
+	finishedAt := time.Now()
+	recorder.current.FinishedAt = &finishedAt
+	errorConsumerLastRunTimestamp.WithLabelValues(recorder.groupId).Set(float64(finishedAt.Unix()))
+	errorConsumerLastRunDuration.WithLabelValues(recorder.groupId).Set(finishedAt.Sub(recorder.current.StartedAt).Seconds())
+	recorder.reports = append(recorder.reports, recorder.current)
+	if len(recorder.reports) > recorder.historySize {
+		recorder.reports = recorder.reports[len(recorder.reports)-recorder.historySize:]
+	}
+	recorder.current = nil
+}
+
+func (recorder *errorConsumerRunRecorder) Reports() []*ErrorConsumerRunReport {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	reports := make([]*ErrorConsumerRunReport, 0, len(recorder.reports)+1)
+	if recorder.current != nil {
+		reports = append(reports, recorder.current.copy())
+	}
+	for i := len(recorder.reports) - 1; i >= 0; i-- {
+		reports = append(reports, recorder.reports[i].copy())
+	}
+	return reports
+}
@@ -0,0 +1,191 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ErrorMessageStatus string
+
+const (
+	ErrorMessagePending  ErrorMessageStatus = "PENDING"
+	ErrorMessageReplayed ErrorMessageStatus = "REPLAYED"
+	ErrorMessageSkipped  ErrorMessageStatus = "SKIPPED"
+
+	errorMessageReplayClaimed  ErrorMessageStatus = "REPLAY_CLAIMED"
+	errorMessageReplayReleased ErrorMessageStatus = "REPLAY_RELEASED"
+)
+
+// replayKeyPrefix separates the replay claims from the operator marks on the status topic.
+const replayKeyPrefix = "replay_"
+
+const (
+	errorMessageStatusTTL       = 7 * 24 * time.Hour
+	errorMessageReplayTTL       = 24 * time.Hour
+	maxErrorMessageRegistrySize = 100000
+)
+
+// errorMessageRegistry remembers error topic messages handled by an operator so the error consumer does not replay them again.
+// With a status store the marks and replay claims are shared through the status topic, a claim saved by another instance after
+// the last refresh is not seen yet. Without a status store they live in memory and only cover the instance until restart.
+type errorMessageRegistry struct {
+	mutex    sync.RWMutex
+	store    *errorMessageStatusStore
+	statuses *expiringMap[ErrorMessageStatus]
+	replays  *expiringMap[struct{}]
+}
+
+func newErrorMessageRegistry(store *errorMessageStatusStore) *errorMessageRegistry {
+	return &errorMessageRegistry{
+		store:    store,
+		statuses: newExpiringMap[ErrorMessageStatus](errorMessageStatusTTL, maxErrorMessageRegistrySize),
+		replays:  newExpiringMap[struct{}](errorMessageReplayTTL, maxErrorMessageRegistrySize),
+	}
+}
+
+func (registry *errorMessageRegistry) set(topic string, partition int32, offset int64, status ErrorMessageStatus) error {
+	key := getErrorMessageKey(topic, partition, offset)
+	if registry.store != nil {
+		if err := registry.store.save(key, status); err != nil {
+			return err
+		}
+	}
+	registry.apply(key, status)
+	return nil
+}
+
+// refresh loads the marks other instances saved since the previous refresh.
+func (registry *errorMessageRegistry) refresh(ctx context.Context) error {
+	if registry.store == nil {
+		return nil
+	}
+	return registry.store.load(ctx, registry.apply)
+}
+
+func (registry *errorMessageRegistry) apply(key string, status ErrorMessageStatus) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if replayKey, isReplay := strings.CutPrefix(key, replayKeyPrefix); isReplay {
+		if status == errorMessageReplayReleased {
+			registry.replays.delete(replayKey)
+		} else {
+			registry.replays.put(replayKey, struct{}{})
+		}
+		return
+	}
+	registry.statuses.put(key, status)
+}
+
+func (registry *errorMessageRegistry) get(topic string, partition int32, offset int64) ErrorMessageStatus {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	if status, exists := registry.statuses.get(getErrorMessageKey(topic, partition, offset)); exists {
+		return status
+	}
+	return ErrorMessagePending
+}
+
+// claimReplay returns false when the same original message with the same error count was already replayed.
+// The claim is saved to the status store before the replay, so other instances skip the message after their next refresh.
+func (registry *errorMessageRegistry) claimReplay(message *ConsumerMessage) (bool, error) {
+	key := getReplayKey(message)
+	registry.mutex.Lock()
+	if _, exists := registry.replays.get(key); exists {
+		registry.mutex.Unlock()
+		return false, nil
+	}
+	registry.replays.put(key, struct{}{})
+	registry.mutex.Unlock()
+	if registry.store != nil {
+		if err := registry.store.save(replayKeyPrefix+key, errorMessageReplayClaimed); err != nil {
+			registry.apply(replayKeyPrefix+key, errorMessageReplayReleased)
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (registry *errorMessageRegistry) releaseReplay(message *ConsumerMessage) error {
+	key := replayKeyPrefix + getReplayKey(message)
+	registry.apply(key, errorMessageReplayReleased)
+	if registry.store != nil {
+		return registry.store.save(key, errorMessageReplayReleased)
+	}
+	return nil
+}
+
+func getReplayKey(message *ConsumerMessage) string {
+	original := getOriginalCoordinates(message)
+	return fmt.Sprintf("%s_%d_%d_%d", original.Topic, original.Partition, original.Offset, getErrorCount(message))
+}
+
+func getErrorMessageKey(topic string, partition int32, offset int64) string {
+	return fmt.Sprintf("%s_%d_%d", topic, partition, offset)
+}
+
+// expiringMap drops entries after the ttl and the oldest entries when it grows over the max size, the caller guards it.
+type expiringMap[V any] struct {
+	ttl     time.Duration
+	maxSize int
+	entries map[string]expiringEntry[V]
+}
+
+type expiringEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func newExpiringMap[V any](ttl time.Duration, maxSize int) *expiringMap[V] {
+	return &expiringMap[V]{
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: make(map[string]expiringEntry[V]),
+	}
+}
+
+func (m *expiringMap[V]) get(key string) (V, bool) {
+	entry, exists := m.entries[key]
+	if !exists || time.Now().After(entry.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (m *expiringMap[V]) put(key string, value V) {
+	if _, exists := m.entries[key]; !exists && len(m.entries) >= m.maxSize {
+		m.prune()
+	}
+	m.entries[key] = expiringEntry[V]{value: value, expiresAt: time.Now().Add(m.ttl)}
+}
+
+func (m *expiringMap[V]) delete(key string) {
+	delete(m.entries, key)
+}
+
+// prune removes the expired entries, then the oldest tenth when every entry is still alive.
+func (m *expiringMap[V]) prune() {
+	now := time.Now()
+	for key, entry := range m.entries {
+		if now.After(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+	if len(m.entries) < m.maxSize {
+		return
+	}
+	keys := make([]string, 0, len(m.entries))
+	for key := range m.entries {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return m.entries[a].expiresAt.Compare(m.entries[b].expiresAt)
+	})
+	for _, key := range keys[:max(len(keys)/10, 1)] {
+		delete(m.entries, key)
+	}
+}
@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"sync"
+	"time"
+)
+
+// errorMessageStatusStore keeps the operator marks on a compacted topic so every instance sees the marks of the others.
+type errorMessageStatusStore struct {
+	mutex         sync.Mutex
+	clusterConfig *ClusterConfig
+	errorConfig   *ConsumerGroupErrorConfig
+	producer      SyncProducer
+	nextOffsets   map[int32]int64
+}
+
+func newErrorMessageStatusStore(clusterConfig *ClusterConfig, errorConfig *ConsumerGroupErrorConfig, producer SyncProducer) *errorMessageStatusStore {
+	return &errorMessageStatusStore{
+		clusterConfig: clusterConfig,
+		errorConfig:   errorConfig,
+		producer:      producer,
+		nextOffsets:   make(map[int32]int64),
+	}
+}
+
+func (store *errorMessageStatusStore) save(key string, status ErrorMessageStatus) error {
+	_, _, err := store.producer.SendMessage(&ProducerMessage{
+		Topic: store.errorConfig.StatusTopic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.StringEncoder(status),
+	})
+	return err
+}
+
+// load reads the marks written since the previous load up to the high watermark taken when the load begins.
+func (store *errorMessageStatusStore) load(ctx context.Context, apply func(key string, status ErrorMessageStatus)) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	client, consumer, err := newErrorTopicConsumer(store.clusterConfig, store.errorConfig)
+	if err != nil {
+		return err
+	}
+	defer closeBrowserConsumer(client, consumer)
+	topic := store.errorConfig.StatusTopic
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return err
+	}
+	for _, partition := range partitions {
+		newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return err
+		}
+		start, exists := store.nextOffsets[partition]
+		if !exists {
+			if start, err = client.GetOffset(topic, partition, sarama.OffsetOldest); err != nil {
+				return err
+			}
+		}
+		if start >= newest {
+			continue
+		}
+		next, err := store.loadPartition(ctx, consumer, topic, partition, start, newest, apply)
+		store.nextOffsets[partition] = next
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *errorMessageStatusStore) loadPartition(ctx context.Context, consumer sarama.Consumer, topic string, partition int32, start int64, end int64, apply func(key string, status ErrorMessageStatus)) (int64, error) {
+	partitionConsumer, err := consumer.ConsumePartition(topic, partition, start)
+	if err != nil {
+		return start, err
+	}
+	defer partitionConsumer.AsyncClose()
+	next := start
+	for next < end {
+		select {
+		case message := <-partitionConsumer.Messages():
+			if message == nil {
+				return next, nil
+			}
+			apply(string(message.Key), ErrorMessageStatus(message.Value))
+			next = message.Offset + 1
+		case err := <-partitionConsumer.Errors():
+			if err != nil {
+				return next, err
+			}
+		case <-time.After(errorTopicScanIdleTimeout):
+			log.Errorf("Error message status topic could not be read up to the high watermark, topic: %s, partition: %d, offset: %d, high watermark: %d", topic, partition, next, end)
+			return next, nil
+		case <-ctx.Done():
+			return next, ctx.Err()
+		}
+	}
+	return next, nil
+}
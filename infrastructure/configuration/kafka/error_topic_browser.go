@@ -0,0 +1,393 @@
+package kafka
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultErrorMessageListLimit = 100
+	maxErrorMessageListLimit     = 1000
+	errorTopicScanIdleTimeout    = 3 * time.Second
+)
+
+type ErrorMessageFilter struct {
+	Topic         string    `json:"topic"`
+	Key           string    `json:"key"`
+	TargetTopic   string    `json:"targetTopic"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	ErrorContains string    `json:"errorContains"`
+	Limit         int       `json:"limit"`
+}
+
+func (filter *ErrorMessageFilter) matches(message *ConsumerMessage) bool {
+	if len(filter.Key) > 0 && string(message.Key) != filter.Key {
+		return false
+	}
+	if len(filter.TargetTopic) > 0 && getTargetTopic(message) != filter.TargetTopic {
+		return false
+	}
+	if !filter.From.IsZero() && message.Timestamp.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && message.Timestamp.After(filter.To) {
+		return false
+	}
+	if len(filter.ErrorContains) > 0 && !strings.Contains(getErrorMessage(message), filter.ErrorContains) {
+		return false
+	}
+	return true
+}
+
+type ErrorMessageCoordinate struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+type ErrorTopicMessage struct {
+	ErrorMessageCoordinate
+	Key          string               `json:"key"`
+	Value        string               `json:"value"`
+	Timestamp    time.Time            `json:"timestamp"`
+	TargetTopic  string               `json:"targetTopic"`
+	ErrorMessage string               `json:"errorMessage"`
+	ErrorCount   int                  `json:"errorCount"`
+	ErrorHistory []ErrorAttempt       `json:"errorHistory,omitempty"`
+	Headers      map[string]string    `json:"headers"`
+	Original     *OriginalCoordinates `json:"original"`
+	Status       ErrorMessageStatus   `json:"status"`
+}
+
+type ErrorReplayFailure struct {
+	ErrorMessageCoordinate
+	Error string `json:"error"`
+}
+
+// ErrorMessageList is partial when a partition stopped returning messages before its high watermark.
+type ErrorMessageList struct {
+	Messages []*ErrorTopicMessage `json:"messages"`
+	Partial  bool                 `json:"partial"`
+}
+
+type ErrorReplayResult struct {
+	DryRun   bool                  `json:"dryRun"`
+	Partial  bool                  `json:"partial"`
+	Matched  int                   `json:"matched"`
+	Replayed int                   `json:"replayed"`
+	Failures []*ErrorReplayFailure `json:"failures,omitempty"`
+	Messages []*ErrorTopicMessage  `json:"messages,omitempty"`
+}
+
+type errorTopicBrowser struct {
+	clusterConfig *ClusterConfig
+	errorConfig   *ConsumerGroupErrorConfig
+	producer      SyncProducer
+	registry      *errorMessageRegistry
+}
+
+func newErrorTopicBrowser(clusterConfig *ClusterConfig, errorConfig *ConsumerGroupErrorConfig, producer SyncProducer, registry *errorMessageRegistry) *errorTopicBrowser {
+	return &errorTopicBrowser{
+		clusterConfig: clusterConfig,
+		errorConfig:   errorConfig,
+		producer:      producer,
+		registry:      registry,
+	}
+}
+
+func (browser *errorTopicBrowser) list(ctx context.Context, filter *ErrorMessageFilter) (*ErrorMessageList, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultErrorMessageListLimit
+	}
+	limit = min(limit, maxErrorMessageListLimit)
+	if err := browser.registry.refresh(ctx); err != nil {
+		return nil, err
+	}
+	result := &ErrorMessageList{Messages: make([]*ErrorTopicMessage, 0)}
+	partial, err := browser.scan(ctx, filter, func(message *ConsumerMessage) bool {
+		result.Messages = append(result.Messages, browser.toErrorTopicMessage(message))
+		return len(result.Messages) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Partial = partial
+	return result, nil
+}
+
+func (browser *errorTopicBrowser) replay(ctx context.Context, coordinates []*ErrorMessageCoordinate) (*ErrorReplayResult, error) {
+	if err := browser.validateCoordinates(coordinates); err != nil {
+		return nil, err
+	}
+	if err := browser.registry.refresh(ctx); err != nil {
+		return nil, err
+	}
+	client, consumer, err := browser.newConsumer()
+	if err != nil {
+		return nil, err
+	}
+	defer closeBrowserConsumer(client, consumer)
+	result := &ErrorReplayResult{Matched: len(coordinates)}
+	for _, coordinate := range coordinates {
+		message, err := browser.fetch(ctx, consumer, coordinate)
+		if err == nil {
+			err = browser.replayMessage(message)
+		}
+		if err != nil {
+			result.Failures = append(result.Failures, &ErrorReplayFailure{ErrorMessageCoordinate: *coordinate, Error: err.Error()})
+			continue
+		}
+		result.Replayed++
+	}
+	return result, nil
+}
+
+func (browser *errorTopicBrowser) skip(coordinates []*ErrorMessageCoordinate) error {
+	if err := browser.validateCoordinates(coordinates); err != nil {
+		return err
+	}
+	for _, coordinate := range coordinates {
+		if err := browser.registry.set(coordinate.Topic, coordinate.Partition, coordinate.Offset, ErrorMessageSkipped); err != nil {
+			return err
+		}
+		log.Infof("Error message skipped, topic: %s, partition: %d, offset: %d", coordinate.Topic, coordinate.Partition, coordinate.Offset)
+	}
+	return nil
+}
+
+func (browser *errorTopicBrowser) replayMatching(ctx context.Context, filter *ErrorMessageFilter, dryRun bool) (*ErrorReplayResult, error) {
+	if err := browser.registry.refresh(ctx); err != nil {
+		return nil, err
+	}
+	result := &ErrorReplayResult{DryRun: dryRun}
+	partial, err := browser.scan(ctx, filter, func(message *ConsumerMessage) bool {
+		if browser.registry.get(message.Topic, message.Partition, message.Offset) != ErrorMessagePending {
+			return true
+		}
+		result.Matched++
+		if len(result.Messages) < maxErrorMessageListLimit {
+			result.Messages = append(result.Messages, browser.toErrorTopicMessage(message))
+		}
+		if !dryRun {
+			if err := browser.replayMessage(message); err != nil {
+				result.Failures = append(result.Failures, &ErrorReplayFailure{
+					ErrorMessageCoordinate: ErrorMessageCoordinate{Topic: message.Topic, Partition: message.Partition, Offset: message.Offset},
+					Error:                  err.Error(),
+				})
+			} else {
+				result.Replayed++
+			}
+		}
+		return filter.Limit <= 0 || result.Matched < filter.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Partial = partial
+	return result, nil
+}
+
+func (browser *errorTopicBrowser) replayMessage(message *ConsumerMessage) error {
+	targetTopic := getTargetTopic(message)
+	if targetTopic == "" {
+		return custom_error.BadRequestErrWithArgs("error message has no target topic, topic: %s, partition: %d, offset: %d", message.Topic, message.Partition, message.Offset)
+	}
+	claimed, err := browser.registry.claimReplay(message)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		original := getOriginalCoordinates(message)
+		return custom_error.BadRequestErrWithArgs("error message was already replayed, original topic: %s, partition: %d, offset: %d", original.Topic, original.Partition, original.Offset)
+	}
+	if err := sendMessageToTopic(browser.producer, message, targetTopic, headersFromErrorToRetry(message)); err != nil {
+		if releaseErr := browser.registry.releaseReplay(message); releaseErr != nil {
+			log.Errorf("Replay claim could not be released, topic: %s, partition: %d, offset: %d, err: %s", message.Topic, message.Partition, message.Offset, releaseErr.Error())
+		}
+		return err
+	}
+	if err := browser.registry.set(message.Topic, message.Partition, message.Offset, ErrorMessageReplayed); err != nil {
+		return custom_error.NewErrWithArgs("error message replayed but its status could not be saved, topic: %s, partition: %d, offset: %d, err: %s", message.Topic, message.Partition, message.Offset, err.Error())
+	}
+	log.Infof("Error message replayed to topic: %s, topic: %s, partition: %d, offset: %d", targetTopic, message.Topic, message.Partition, message.Offset)
+	return nil
+}
+
+// scan reads the error topics from the filter start up to the high watermark taken when the scan begins.
+// It reports partial when a partition stopped returning messages before the high watermark.
+func (browser *errorTopicBrowser) scan(ctx context.Context, filter *ErrorMessageFilter, visit func(message *ConsumerMessage) bool) (bool, error) {
+	topics := browser.errorConfig.Topics
+	if len(filter.Topic) > 0 {
+		if !slices.Contains(topics, filter.Topic) {
+			return false, custom_error.NotFoundErrWithArgs("error topic not found, groupId: %s, topic: %s", browser.errorConfig.GroupId, filter.Topic)
+		}
+		topics = []string{filter.Topic}
+	}
+	client, consumer, err := browser.newConsumer()
+	if err != nil {
+		return false, err
+	}
+	defer closeBrowserConsumer(client, consumer)
+	partial := false
+	for _, topic := range topics {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			return false, err
+		}
+		for _, partition := range partitions {
+			newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return false, err
+			}
+			start := sarama.OffsetOldest
+			if !filter.From.IsZero() {
+				start = filter.From.UnixMilli()
+			}
+			start, err = client.GetOffset(topic, partition, start)
+			if err != nil {
+				return false, err
+			}
+			if start < 0 || start >= newest {
+				continue
+			}
+			next, complete, err := browser.scanPartition(ctx, consumer, topic, partition, start, newest, filter, visit)
+			if err != nil {
+				return false, err
+			}
+			partial = partial || !complete
+			if !next {
+				return partial, nil
+			}
+		}
+	}
+	return partial, nil
+}
+
+// scanPartition returns whether the scan should go on and whether the partition was read up to end.
+func (browser *errorTopicBrowser) scanPartition(ctx context.Context, consumer sarama.Consumer, topic string, partition int32, start int64, end int64, filter *ErrorMessageFilter, visit func(message *ConsumerMessage) bool) (bool, bool, error) {
+	partitionConsumer, err := consumer.ConsumePartition(topic, partition, start)
+	if err != nil {
+		return false, false, err
+	}
+	defer partitionConsumer.AsyncClose()
+	idleTimer := time.NewTimer(errorTopicScanIdleTimeout)
+	defer idleTimer.Stop()
+	for {
+		select {
+		case message := <-partitionConsumer.Messages():
+			if message == nil {
+				return true, false, nil
+			}
+			consumerMessage := &ConsumerMessage{ConsumerMessage: message, GroupId: browser.errorConfig.GroupId}
+			if filter.matches(consumerMessage) && !visit(consumerMessage) {
+				return false, true, nil
+			}
+			if message.Offset+1 >= end {
+				return true, true, nil
+			}
+			idleTimer.Reset(errorTopicScanIdleTimeout)
+		case err := <-partitionConsumer.Errors():
+			if err != nil {
+				return false, false, err
+			}
+		case <-idleTimer.C:
+			log.Errorf("Error topic scan timed out before the high watermark, topic: %s, partition: %d, high watermark: %d", topic, partition, end)
+			return true, false, nil
+		case <-ctx.Done():
+			return false, false, ctx.Err()
+		}
+	}
+}
+
+func (browser *errorTopicBrowser) fetch(ctx context.Context, consumer sarama.Consumer, coordinate *ErrorMessageCoordinate) (*ConsumerMessage, error) {
+	partitionConsumer, err := consumer.ConsumePartition(coordinate.Topic, coordinate.Partition, coordinate.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer partitionConsumer.AsyncClose()
+	select {
+	case message := <-partitionConsumer.Messages():
+		if message == nil || message.Offset != coordinate.Offset {
+			return nil, custom_error.NotFoundErrWithArgs("error message not found, topic: %s, partition: %d, offset: %d", coordinate.Topic, coordinate.Partition, coordinate.Offset)
+		}
+		return &ConsumerMessage{ConsumerMessage: message, GroupId: browser.errorConfig.GroupId}, nil
+	case err := <-partitionConsumer.Errors():
+		return nil, err
+	case <-time.After(errorTopicScanIdleTimeout):
+		return nil, custom_error.NotFoundErrWithArgs("error message not found, topic: %s, partition: %d, offset: %d", coordinate.Topic, coordinate.Partition, coordinate.Offset)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (browser *errorTopicBrowser) validateCoordinates(coordinates []*ErrorMessageCoordinate) error {
+	if len(coordinates) == 0 {
+		return custom_error.BadRequestErr("at least one error message required")
+	}
+	for _, coordinate := range coordinates {
+		if !slices.Contains(browser.errorConfig.Topics, coordinate.Topic) {
+			return custom_error.BadRequestErrWithArgs("topic is not an error topic of groupId: %s, topic: %s", browser.errorConfig.GroupId, coordinate.Topic)
+		}
+	}
+	return nil
+}
+
+func (browser *errorTopicBrowser) newConsumer() (Client, sarama.Consumer, error) {
+	return newErrorTopicConsumer(browser.clusterConfig, browser.errorConfig)
+}
+
+func newErrorTopicConsumer(clusterConfig *ClusterConfig, errorConfig *ConsumerGroupErrorConfig) (Client, sarama.Consumer, error) {
+	saramaConfig, err := getSaramaErrorConfig(clusterConfig, errorConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := NewClient(saramaConfig, clusterConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, nil, err
+	}
+	return client, consumer, nil
+}
+
+func (browser *errorTopicBrowser) toErrorTopicMessage(message *ConsumerMessage) *ErrorTopicMessage {
+	headers := make(map[string]string, len(message.Headers))
+	for _, header := range message.Headers {
+		if ContextKey(header.Key) == ErrorHistoryKey {
+			continue
+		}
+		headers[string(header.Key)] = string(header.Value)
+	}
+	return &ErrorTopicMessage{
+		ErrorMessageCoordinate: ErrorMessageCoordinate{Topic: message.Topic, Partition: message.Partition, Offset: message.Offset},
+		Key:                    string(message.Key),
+		Value:                  string(message.Value),
+		Timestamp:              message.Timestamp,
+		TargetTopic:            getTargetTopic(message),
+		ErrorMessage:           getErrorMessage(message),
+		ErrorCount:             getErrorCount(message),
+		ErrorHistory:           getErrorHistory(message),
+		Headers:                headers,
+		Original:               getOriginalCoordinates(message),
+		Status:                 browser.registry.get(message.Topic, message.Partition, message.Offset),
+	}
+}
+
+func closeBrowserConsumer(client Client, consumer sarama.Consumer) {
+	if err := consumer.Close(); err != nil {
+		log.Errorf("An error occurred when closing error topic consumer, err: %s", err.Error())
+	}
+	if err := client.Close(); err != nil {
+		log.Errorf("An error occurred when closing error topic client, err: %s", err.Error())
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/IBM/sarama"
+	"hash/fnv"
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
 	"presentation-advert-consumer/infrastructure/configuration/log"
 	"strings"
 	"time"
@@ -72,71 +74,222 @@ func unsubscribe(client sarama.Client, consumerGroup sarama.ConsumerGroup) error
 	return nil
 }
 
-func processMessage(ctx context.Context, consumer Consumer, message *ConsumerMessage, maxProcessingTime time.Duration) error {
+// processMessage retries retryable errors in process with the local retry policy, every attempt shares the max processing time budget.
+func processMessage(ctx context.Context, consumer Consumer, message *ConsumerMessage, maxProcessingTime time.Duration, localRetry *LocalRetryPolicy, classify ErrorClassifier, tracker *abandonedHandlerTracker) error {
 	contextWithTimeout, cancel := context.WithTimeout(ctx, maxProcessingTime)
 	defer cancel()
+	for attempt := 1; ; attempt++ {
+		err := consumeMessage(contextWithTimeout, consumer, message, tracker)
+		if attempt > 1 {
+			consumerLocalRetryAttemptCounter.WithLabelValues(message.GroupId, message.Topic, getLocalRetryResult(err)).Inc()
+		}
+		if err == nil || !localRetry.shouldRetry(attempt, err, classify) {
+			return err
+		}
+		backoff := localRetry.GetBackoff(attempt)
+		if deadline, ok := contextWithTimeout.Deadline(); ok && time.Until(deadline) <= backoff {
+			return err
+		}
+		log.Errorf("Retrying message in process, topic: %s, partition: %d, offset: %d, attempt: %d, retry in: %s, err: %s",
+			message.Topic, message.Partition, message.Offset, attempt, backoff, err.Error())
+		select {
+		case <-time.After(backoff):
+		case <-contextWithTimeout.Done():
+			return err
+		}
+	}
+}
+
+// consumeMessage hands a copy of the message to the consumer, the headers it adds are kept only when it returns in time.
+func consumeMessage(contextWithTimeout context.Context, consumer Consumer, message *ConsumerMessage, tracker *abandonedHandlerTracker) error {
+	consumed := cloneConsumerMessage(message)
 	resultChan := make(chan error, 1)
 	go func(r chan<- error) {
-		r <- consumer.Consume(contextWithTimeout, message)
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				panicErr := recoverConsumerPanic(message.GroupId, message.Topic, recovered)
+				log.Errorf("Consumer panicked, topic: %s, partition: %d, offset: %d, err: %s, stack: %s", message.Topic, message.Partition, message.Offset, panicErr.Error(), panicErr.Stack)
+				r <- panicErr
+			}
+		}()
+		r <- consumer.Consume(contextWithTimeout, consumed)
 	}(resultChan)
 	select {
 	case or := <-resultChan:
+		message.Headers = consumed.Headers
 		return or
 	case <-contextWithTimeout.Done():
-		return contextWithTimeout.Err()
+		return waitAbandonedHandler(contextWithTimeout.Err(), resultChan, message.Topic, message.Partition, tracker)
+	}
+}
+
+func getLocalRetryResult(err error) string {
+	if err == nil {
+		return "success"
+	}
+	return "failure"
+}
+
+func processBatchMessages(ctx context.Context, consumer BatchConsumer, messages []*ConsumerMessage, maxProcessingTime time.Duration, tracker *abandonedHandlerTracker) map[*ConsumerMessage]error {
+	contextWithTimeout, cancel := context.WithTimeout(ctx, maxProcessingTime)
+	defer cancel()
+	consumed := make([]*ConsumerMessage, 0, len(messages))
+	originals := make(map[*ConsumerMessage]*ConsumerMessage, len(messages))
+	for _, message := range messages {
+		clone := cloneConsumerMessage(message)
+		consumed = append(consumed, clone)
+		originals[clone] = message
+	}
+	resultChan := make(chan error, 1)
+	go func(r chan<- error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				first := messages[0]
+				panicErr := recoverConsumerPanic(first.GroupId, first.Topic, recovered)
+				log.Errorf("Batch consumer panicked, topic: %s, partition: %d, offsets: %d-%d, err: %s, stack: %s", first.Topic, first.Partition, first.Offset, messages[len(messages)-1].Offset, panicErr.Error(), panicErr.Stack)
+				r <- panicErr
+			}
+		}()
+		r <- consumer.ConsumeBatch(contextWithTimeout, consumed)
+	}(resultChan)
+	var err error
+	select {
+	case err = <-resultChan:
+		for clone, message := range originals {
+			message.Headers = clone.Headers
+		}
+	case <-contextWithTimeout.Done():
+		err = waitAbandonedHandler(contextWithTimeout.Err(), resultChan, messages[0].Topic, messages[0].Partition, tracker)
 	}
+	if err == nil {
+		return nil
+	}
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		failedMessages := make(map[*ConsumerMessage]error, len(batchErr.Errors))
+		for clone, messageErr := range batchErr.Errors {
+			if message, exists := originals[clone]; exists {
+				failedMessages[message] = messageErr
+			}
+		}
+		return failedMessages
+	}
+	failedMessages := make(map[*ConsumerMessage]error, len(messages))
+	for _, message := range messages {
+		failedMessages[message] = err
+	}
+	return failedMessages
+}
+
+// waitAbandonedHandler keeps track of a timed out handler until it returns, with the block policy it also waits for the result.
+func waitAbandonedHandler(timeoutErr error, resultChan <-chan error, topic string, partition int32, tracker *abandonedHandlerTracker) error {
+	if tracker == nil {
+		return timeoutErr
+	}
+	tracker.abandon(topic, partition)
+	if tracker.blocks() {
+		err := <-resultChan
+		tracker.release(topic, partition)
+		return err
+	}
+	go func() {
+		<-resultChan
+		tracker.release(topic, partition)
+	}()
+	return timeoutErr
 }
 
-func processConsumedMessageError(ctx context.Context, message *ConsumerMessage, err error, producer SyncProducer, consumerTopicConfig *ConsumerGroupConfig) {
+// processConsumedMessageError hands a failed message off and returns the topic it was sent to, empty when it was dropped.
+func processConsumedMessageError(ctx context.Context, message *ConsumerMessage, err error, producer SyncProducer, consumerTopicConfig *ConsumerGroupConfig) (string, error) {
+	if errors.Is(err, context.DeadlineExceeded) && consumerTopicConfig.TimeoutPolicy == TimeoutPolicyError && consumerTopicConfig.IsDefinedErrorTopic() {
+		return sendMessageToErrorTopic(message, err, producer, consumerTopicConfig, getTimeoutErrorHeaders(message, err, consumerTopicConfig))
+	}
+	switch consumerTopicConfig.ClassifyError(err) {
+	case custom_error.ErrorClassSkip:
+		log.Infof("Skipped failed message, topic: %s, partition: %d, offset: %d, err: %s", message.Topic, message.Partition, message.Offset, err.Error())
+		consumerSkippedMessageCounter.WithLabelValues(message.GroupId, message.Topic).Inc()
+		return "", nil
+	case custom_error.ErrorClassPermanent:
+		if consumerTopicConfig.IsNotDefinedErrorTopic() {
+			log.Errorf("Dropped message with permanent error, error topic is not defined, topic: %s, partition: %d, offset: %d, err: %s", message.Topic, message.Partition, message.Offset, err.Error())
+			return "", nil
+		}
+		return sendMessageToErrorTopic(message, err, producer, consumerTopicConfig, headersForPermanentError(message, err.Error()))
+	}
 	if isMainTopic(message, consumerTopicConfig) {
-		processConsumedMainTopicMessageError(ctx, message, err, producer, consumerTopicConfig)
-		return
+		return processConsumedMainTopicMessageError(ctx, message, err, producer, consumerTopicConfig)
 	}
 
 	if isRetryTopic(message, consumerTopicConfig) {
-		processConsumedRetryTopicMessageError(ctx, message, err, producer, consumerTopicConfig)
-		return
+		return processConsumedRetryTopicMessageError(ctx, message, err, producer, consumerTopicConfig)
 	}
+	return "", nil
 }
 
-func processConsumedMainTopicMessageError(ctx context.Context, message *ConsumerMessage, err error, producer SyncProducer, consumerTopicConfig *ConsumerGroupConfig) {
+func processConsumedMainTopicMessageError(ctx context.Context, message *ConsumerMessage, err error, producer SyncProducer, consumerTopicConfig *ConsumerGroupConfig) (string, error) {
 	if consumerTopicConfig.IsNotDefinedRetryAndErrorTopic() {
-		return
+		return "", nil
 	}
 	if consumerTopicConfig.IsNotDefinedRetryTopic() && consumerTopicConfig.IsDefinedErrorTopic() {
-		messageSendError := sendMessageToTopic(producer, message, consumerTopicConfig.Error, headersForError(message, err.Error()))
+		messageSendError := sendMessageToTopic(producer, message, consumerTopicConfig.Error, withPanicStack(headersForError(message, err.Error()), err))
 		if messageSendError != nil {
 			log.Errorf("An error occurred when sent error message to error topic: %s, err: %s", consumerTopicConfig.Error, messageSendError.Error())
 		}
-		return
+		return consumerTopicConfig.Error, messageSendError
 	}
-	messageSendError := sendMessageToTopic(producer, message, consumerTopicConfig.Retry, headersForRetry(message, err.Error()))
+	return sendMessageToRetryTier(message, err, producer, consumerTopicConfig.RetryTiers[0], 0)
+}
+
+func processConsumedRetryTopicMessageError(ctx context.Context, message *ConsumerMessage, err error, producer SyncProducer, consumerTopicConfig *ConsumerGroupConfig) (string, error) {
+	tierIndex := consumerTopicConfig.GetRetryTierIndex(message.Topic)
+	tier := consumerTopicConfig.RetryTiers[tierIndex]
+	retriedCount := getRetriedCount(message)
+	if retriedCount < tier.Attempts {
+		return sendMessageToRetryTier(message, err, producer, tier, retriedCount)
+	}
+	if tierIndex+1 < len(consumerTopicConfig.RetryTiers) {
+		return sendMessageToRetryTier(message, err, producer, consumerTopicConfig.RetryTiers[tierIndex+1], 0)
+	}
+	if consumerTopicConfig.IsNotDefinedErrorTopic() {
+		return "", nil
+	}
+	reachedMaxRetryCountErr := fmt.Errorf("reached max rety count, retriedCount: %d", retriedCount)
+	joinedErr := errors.Join(err, reachedMaxRetryCountErr)
+	messageSendError := sendMessageToTopic(producer, message, consumerTopicConfig.Error, withPanicStack(headersFromRetryToError(message, joinedErr.Error()), err))
 	if messageSendError != nil {
-		joinedErr := errors.Join(err, messageSendError)
+		joinedErr = errors.Join(joinedErr, messageSendError)
 		log.Errorf("An error occurred when sent error message to error topic: %s, err: %s", consumerTopicConfig.Error, joinedErr)
 	}
+	return consumerTopicConfig.Error, messageSendError
 }
 
-func processConsumedRetryTopicMessageError(ctx context.Context, message *ConsumerMessage, err error, producer SyncProducer, consumerTopicConfig *ConsumerGroupConfig) {
-	retriedCount := getRetriedCount(message)
-	if retriedCount >= consumerTopicConfig.RetryCount && consumerTopicConfig.IsNotDefinedErrorTopic() {
-		return
+func sendMessageToRetryTier(message *ConsumerMessage, err error, producer SyncProducer, tier *RetryTier, retriedCount int) (string, error) {
+	messageSendError := sendMessageToTopic(producer, message, tier.Topic, withPanicStack(headersToRetryTier(message, err.Error(), retriedCount, tier.GetDelay(retriedCount)), err))
+	if messageSendError != nil {
+		joinedErr := errors.Join(err, messageSendError)
+		log.Errorf("An error occurred when sent error message to retry topic: %s, err: %s", tier.Topic, joinedErr)
 	}
-	if retriedCount >= consumerTopicConfig.RetryCount && consumerTopicConfig.IsDefinedErrorTopic() {
-		reachedMaxRetryCountErr := fmt.Errorf("reached max rety count, retriedCount: %d", retriedCount)
-		joinedErr := errors.Join(err, reachedMaxRetryCountErr)
-		messageSendError := sendMessageToTopic(producer, message, consumerTopicConfig.Error, headersFromRetryToError(message, joinedErr.Error()))
-		if messageSendError != nil {
-			joinedErr = errors.Join(joinedErr, messageSendError)
-			log.Errorf("An error occurred when sent error message to error topic: %s, err: %s", consumerTopicConfig.Error, joinedErr)
-		}
-		return
+	return tier.Topic, messageSendError
+}
+
+// getTimeoutErrorHeaders lets the error consumer requeue a timed out main topic message into the first retry tier, never back into the main topic.
+func getTimeoutErrorHeaders(message *ConsumerMessage, err error, consumerTopicConfig *ConsumerGroupConfig) []sarama.RecordHeader {
+	if !isMainTopic(message, consumerTopicConfig) {
+		return headersFromRetryToError(message, err.Error())
 	}
-	messageSendError := sendMessageToTopic(producer, message, consumerTopicConfig.Retry, headersFromRetryToRetry(message, err.Error(), retriedCount))
+	if consumerTopicConfig.IsNotDefinedRetryTopic() {
+		return headersForError(message, err.Error())
+	}
+	return headersForTimeoutError(message, err.Error(), consumerTopicConfig.RetryTiers[0].Topic)
+}
+
+func sendMessageToErrorTopic(message *ConsumerMessage, err error, producer SyncProducer, consumerTopicConfig *ConsumerGroupConfig, headers []sarama.RecordHeader) (string, error) {
+	messageSendError := sendMessageToTopic(producer, message, consumerTopicConfig.Error, withPanicStack(headers, err))
 	if messageSendError != nil {
 		joinedErr := errors.Join(err, messageSendError)
 		log.Errorf("An error occurred when sent error message to error topic: %s, err: %s", consumerTopicConfig.Error, joinedErr)
 	}
+	return consumerTopicConfig.Error, messageSendError
 }
 
 func sendMessageToTopic(producer SyncProducer, message *ConsumerMessage, topic string, headers []sarama.RecordHeader) error {
@@ -149,6 +302,23 @@ func sendMessageToTopic(producer SyncProducer, message *ConsumerMessage, topic s
 	return err
 }
 
+func observeEndToEndDelay(message *ConsumerMessage) {
+	if !hasOriginalCoordinates(message) {
+		return
+	}
+	original := getOriginalCoordinates(message)
+	consumerEndToEndDelay.WithLabelValues(message.GroupId, original.Topic, message.Topic).Observe(time.Since(original.Timestamp).Seconds())
+}
+
 func getTopicPartitionKey(topic string, partition int32) string {
 	return fmt.Sprintf("%s_%d", topic, partition)
 }
+
+func getWorkerIndex(message *sarama.ConsumerMessage, workerCount int) int {
+	if len(message.Key) == 0 {
+		return int(message.Offset % int64(workerCount))
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write(message.Key)
+	return int(hash.Sum32() % uint32(workerCount))
+}
@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	consumerPartitionLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_partition_lag",
+		Help: "High watermark minus the next offset to consume for a claimed partition",
+	}, []string{"group", "topic", "partition"})
+	consumerPartitionTimeLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_partition_time_lag_seconds",
+		Help: "Age of the latest consumed message timestamp when the claimed partition has lag",
+	}, []string{"group", "topic", "partition"})
+	consumerMessageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "kafka_consumer_message_duration_seconds",
+		Help: "Time spent by the consumer on a single message",
+	}, []string{"group", "topic", "result"})
+	consumerBatchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "kafka_consumer_batch_duration_seconds",
+		Help: "Time spent by the batch consumer on a single batch",
+	}, []string{"group", "topic", "result"})
+	consumerBatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_consumer_batch_size",
+		Help:    "Number of messages passed to the batch consumer",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{"group", "topic"})
+	consumerStuckHandlers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_stuck_handlers",
+		Help: "Handlers still running after max processing time was exceeded",
+	}, []string{"group", "topic", "partition"})
+	consumerOrderedRetryKeys = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_ordered_retry_keys",
+		Help: "Keys with messages in retry that hold back later messages of the same key",
+	}, []string{"group"})
+	consumerOrderedRetryDivertedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_ordered_retry_diverted_messages_total",
+		Help: "Messages sent to retry without processing because an earlier message of their key is still in retry",
+	}, []string{"group", "topic"})
+	consumerLocalRetryAttemptCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_local_retry_attempts_total",
+		Help: "In process retry attempts made before a message is handed off to the retry topic",
+	}, []string{"group", "topic", "result"})
+	consumerSkippedMessageCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_skipped_messages_total",
+		Help: "Failed messages dropped because their error is classified as skip",
+	}, []string{"group", "topic"})
+	errorConsumerParkedMessageCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_error_consumer_parked_messages_total",
+		Help: "Messages moved to the parking lot topic after reaching max error count",
+	}, []string{"group", "topic"})
+	errorConsumerRunCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_error_consumer_runs_total",
+		Help: "Error consumer runs started by cron or manual trigger",
+	}, []string{"group", "trigger"})
+	errorConsumerRunMessageCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_error_consumer_run_messages_total",
+		Help: "Messages seen by error consumer runs by result",
+	}, []string{"group", "topic", "result"})
+	errorConsumerLastRunTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_error_consumer_last_run_finished_timestamp_seconds",
+		Help: "Finish time of the last error consumer run",
+	}, []string{"group"})
+	errorConsumerLastRunDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_error_consumer_last_run_duration_seconds",
+		Help: "Duration of the last error consumer run",
+	}, []string{"group"})
+	consumerEndToEndDelay = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_consumer_end_to_end_delay_seconds",
+		Help:    "Time from the original message timestamp until a retried message is processed successfully",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+	}, []string{"group", "original_topic", "topic"})
+	consumerPanicCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_consumer_panics_total",
+		Help: "Number of panics recovered while consuming messages",
+	}, []string{"group", "topic"})
+)
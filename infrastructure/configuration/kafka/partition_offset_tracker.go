@@ -0,0 +1,47 @@
+package kafka
+
+import (
+	"github.com/IBM/sarama"
+	"sync"
+)
+
+// partitionOffsetTracker marks a message only after every earlier offset of the partition is done.
+type partitionOffsetTracker struct {
+	mutex    sync.Mutex
+	session  sarama.ConsumerGroupSession
+	pending  []*sarama.ConsumerMessage
+	finished map[int64]struct{}
+}
+
+func newPartitionOffsetTracker(session sarama.ConsumerGroupSession) *partitionOffsetTracker {
+	return &partitionOffsetTracker{
+		session:  session,
+		pending:  make([]*sarama.ConsumerMessage, 0),
+		finished: make(map[int64]struct{}),
+	}
+}
+
+func (tracker *partitionOffsetTracker) add(message *sarama.ConsumerMessage) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.pending = append(tracker.pending, message)
+}
+
+func (tracker *partitionOffsetTracker) done(message *sarama.ConsumerMessage) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.finished[message.Offset] = struct{}{}
+	var markable *sarama.ConsumerMessage
+	for len(tracker.pending) > 0 {
+		head := tracker.pending[0]
+		if _, exists := tracker.finished[head.Offset]; !exists {
+			break
+		}
+		delete(tracker.finished, head.Offset)
+		tracker.pending = tracker.pending[1:]
+		markable = head
+	}
+	if markable != nil {
+		tracker.session.MarkMessage(markable, "")
+	}
+}
@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"github.com/IBM/sarama"
+	"slices"
+	"testing"
+)
+
+type markRecordingSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (session *markRecordingSession) MarkMessage(message *sarama.ConsumerMessage, _ string) {
+	session.marked = append(session.marked, message.Offset)
+}
+
+func TestPartitionOffsetTracker_Done(t *testing.T) {
+	tests := []struct {
+		name     string
+		offsets  []int64
+		done     []int64
+		expected []int64
+	}{
+		{name: "in order", offsets: []int64{1, 2, 3}, done: []int64{1, 2, 3}, expected: []int64{1, 2, 3}},
+		{name: "reverse order", offsets: []int64{1, 2, 3}, done: []int64{3, 2, 1}, expected: []int64{3}},
+		{name: "gap is filled later", offsets: []int64{1, 2, 3, 4}, done: []int64{1, 3, 4, 2}, expected: []int64{1, 4}},
+		{name: "head is not done", offsets: []int64{1, 2, 3}, done: []int64{2, 3}, expected: nil},
+		{name: "non consecutive offsets", offsets: []int64{5, 9, 12}, done: []int64{9, 5, 12}, expected: []int64{9, 12}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := &markRecordingSession{}
+			tracker := newPartitionOffsetTracker(session)
+			messages := make(map[int64]*sarama.ConsumerMessage, len(test.offsets))
+			for _, offset := range test.offsets {
+				messages[offset] = &sarama.ConsumerMessage{Offset: offset}
+				tracker.add(messages[offset])
+			}
+			for _, offset := range test.done {
+				tracker.done(messages[offset])
+			}
+			if !slices.Equal(session.marked, test.expected) {
+				t.Errorf("marked offsets = %v, expected %v", session.marked, test.expected)
+			}
+		})
+	}
+}
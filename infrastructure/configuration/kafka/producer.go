@@ -7,7 +7,9 @@ import (
 	"github.com/IBM/sarama"
 	"presentation-advert-consumer/infrastructure/configuration/custom_error"
 	"presentation-advert-consumer/infrastructure/configuration/custom_json"
+	"presentation-advert-consumer/infrastructure/configuration/log"
 	"strings"
+	"time"
 )
 
 type ProducerMessage = sarama.ProducerMessage
@@ -17,23 +19,32 @@ type Producer interface {
 	GetSyncProducer(clusterName string) (SyncProducer, error)
 	GetProducerTopic(configName string) (*ProducerTopic, error)
 	ProduceSync(ctx context.Context, message Message) error
-	ProduceSyncBulk(ctx context.Context, messages []Message, size int) error
+	ProduceSyncBulk(ctx context.Context, messages []Message, size int, options ...BulkProduceOption) (*BulkProduceResult, error)
 	ProduceCustomSync(ctx context.Context, message *CustomMessage) error
-	ProduceCustomSyncBulk(ctx context.Context, messages []*CustomMessage, size int) error
+	ProduceCustomSyncBulk(ctx context.Context, messages []*CustomMessage, size int, options ...BulkProduceOption) (*BulkProduceResult, error)
+	ProduceAsync(ctx context.Context, message Message) error
+	ProduceAsyncBulk(ctx context.Context, messages []Message) error
+	Close() error
 }
 
 type producer struct {
 	syncProducerMap        map[string]SyncProducer
+	asyncProducerMap       map[string]AsyncProducer
 	producerTopicConfigMap ProducerTopicConfigMap
+	interceptors           []ProducerInterceptor
 }
 
 func NewProducer(
 	syncProducerMap map[string]SyncProducer,
+	asyncProducerMap map[string]AsyncProducer,
 	producerTopicConfigMap ProducerTopicConfigMap,
+	interceptors ...ProducerInterceptor,
 ) (Producer, error) {
 	return &producer{
 		syncProducerMap:        syncProducerMap,
+		asyncProducerMap:       asyncProducerMap,
 		producerTopicConfigMap: producerTopicConfigMap,
+		interceptors:           interceptors,
 	}, nil
 }
 
@@ -51,12 +62,20 @@ func (c *producer) GetSyncProducer(clusterName string) (SyncProducer, error) {
 	return producer, nil
 }
 
+func (c *producer) GetAsyncProducer(clusterName string) (AsyncProducer, error) {
+	producer, exist := c.asyncProducerMap[strings.ToLower(clusterName)]
+	if !exist {
+		return nil, custom_error.NewErrWithArgs("kafka async producer not found. cluster name: %s", clusterName)
+	}
+	return producer, nil
+}
+
 func (c *producer) GetProducerTopic(configName string) (*ProducerTopic, error) {
 	return c.producerTopicConfigMap.GetConfig(configName)
 }
 
-func (c *producer) ProduceSync(_ context.Context, message Message) error {
-	produceMessage, err := c.getProduceMessageFromMessage(message)
+func (c *producer) ProduceSync(ctx context.Context, message Message) error {
+	produceMessage, err := c.getProduceMessageFromMessage(ctx, message)
 	if err != nil {
 		return err
 	}
@@ -70,88 +89,178 @@ func (c *producer) ProduceSync(_ context.Context, message Message) error {
 	return nil
 }
 
-func (c *producer) ProduceSyncBulk(_ context.Context, messages []Message, size int) error {
+func (c *producer) ProduceSyncBulk(ctx context.Context, messages []Message, size int, options ...BulkProduceOption) (*BulkProduceResult, error) {
 	mappedProducerMessages := make([]*producerMessage, 0, len(messages))
 	for _, message := range messages {
-		produceMessage, err := c.getProduceMessageFromMessage(message)
+		produceMessage, err := c.getProduceMessageFromMessage(ctx, message)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		mappedProducerMessages = append(mappedProducerMessages, produceMessage)
 	}
-	slicedProducerMessages, err := c.splitAndSliceKafKaMessages(mappedProducerMessages, size)
+	return c.produceSyncBulk(ctx, mappedProducerMessages, size, options...)
+}
+
+func (c *producer) ProduceCustomSync(ctx context.Context, message *CustomMessage) error {
+	produceMessage, err := c.getProduceMessageFromCustomMessage(ctx, message)
+	if err != nil {
+		return err
+	}
+	syncProducer, err := c.GetSyncProducer(message.Topic.Cluster)
 	if err != nil {
 		return err
 	}
-	for _, producerMessages := range slicedProducerMessages {
-		topic := producerMessages[0].Topic.Name
-		clusterName := producerMessages[0].Topic.Cluster
-		syncProducer, err := c.GetSyncProducer(clusterName)
+	if _, _, err = syncProducer.SendMessage(produceMessage.ProducerMessage); err != nil {
+		return errors.Join(err, fmt.Errorf("produce custom sync err: %s, topic: %s, cluster: %s", err.Error(), produceMessage.Topic.Name, produceMessage.Topic.Cluster))
+	}
+	return nil
+}
+
+func (c *producer) ProduceCustomSyncBulk(ctx context.Context, messages []*CustomMessage, size int, options ...BulkProduceOption) (*BulkProduceResult, error) {
+	mappedProducerMessages := make([]*producerMessage, 0, len(messages))
+	for _, message := range messages {
+		saramaProduceMessage, err := c.getProduceMessageFromCustomMessage(ctx, message)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		mappedProducerMessages = append(mappedProducerMessages, saramaProduceMessage)
+	}
+	return c.produceSyncBulk(ctx, mappedProducerMessages, size, options...)
+}
+
+// produceSyncBulk keeps a result per message in the given order, with the retry option only the failed messages are sent again.
+func (c *producer) produceSyncBulk(ctx context.Context, producerMessages []*producerMessage, size int, options ...BulkProduceOption) (*BulkProduceResult, error) {
+	bulkOptions := &bulkProduceOptions{}
+	for _, option := range options {
+		option(bulkOptions)
+	}
+	bulkResult := &BulkProduceResult{Results: make([]*BulkProduceMessageResult, 0, len(producerMessages))}
+	messageResults := make(map[*ProducerMessage]*BulkProduceMessageResult, len(producerMessages))
+	for i, producerMessage := range producerMessages {
+		messageResult := &BulkProduceMessageResult{Index: i, Topic: producerMessage.Topic.Name, Cluster: producerMessage.Topic.Cluster}
+		bulkResult.Results = append(bulkResult.Results, messageResult)
+		messageResults[producerMessage.ProducerMessage] = messageResult
+	}
+	backoff := bulkOptions.retryBackoff
+	pendingMessages := producerMessages
+	for attempt := 0; ; attempt++ {
+		c.sendSyncBulk(pendingMessages, size, messageResults)
+		failedMessages := make([]*producerMessage, 0)
+		for _, producerMessage := range pendingMessages {
+			if messageResults[producerMessage.ProducerMessage].Err != nil {
+				failedMessages = append(failedMessages, producerMessage)
+			}
+		}
+		if len(failedMessages) == 0 || attempt >= bulkOptions.retryAttempts {
+			break
+		}
+		log.Errorf("Bulk produce failed for %d of %d messages, retry in: %s, attempt: %d", len(failedMessages), len(producerMessages), backoff, attempt+1)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return bulkResult, errors.Join(bulkResult.Err(), ctx.Err())
 		}
+		backoff *= 2
+		pendingMessages = failedMessages
+	}
+	return bulkResult, bulkResult.Err()
+}
+
+func (c *producer) sendSyncBulk(producerMessages []*producerMessage, size int, messageResults map[*ProducerMessage]*BulkProduceMessageResult) {
+	slicedProducerMessages, err := c.splitAndSliceKafKaMessages(producerMessages, size)
+	if err != nil {
+		for _, producerMessage := range producerMessages {
+			messageResults[producerMessage.ProducerMessage].Err = err
+		}
+		return
+	}
+	for _, producerMessages := range slicedProducerMessages {
 		saramaMessages := make([]*ProducerMessage, 0, len(producerMessages))
 		for _, producerMessage := range producerMessages {
 			saramaMessages = append(saramaMessages, producerMessage.ProducerMessage)
 		}
-		if err = syncProducer.SendMessages(saramaMessages); err != nil {
-			return errors.Join(err, fmt.Errorf("produce sync bulk err: %s, topic: %s, cluster: %s", err.Error(), topic, clusterName))
+		syncProducer, err := c.GetSyncProducer(producerMessages[0].Topic.Cluster)
+		if err == nil {
+			err = syncProducer.SendMessages(saramaMessages)
+		}
+		failedMessages := make(map[*ProducerMessage]error)
+		var producerErrors sarama.ProducerErrors
+		if errors.As(err, &producerErrors) {
+			for _, producerError := range producerErrors {
+				failedMessages[producerError.Msg] = producerError.Err
+			}
+		} else if err != nil {
+			for _, saramaMessage := range saramaMessages {
+				failedMessages[saramaMessage] = err
+			}
+		}
+		for _, saramaMessage := range saramaMessages {
+			messageResult := messageResults[saramaMessage]
+			messageResult.Err = failedMessages[saramaMessage]
+			if messageResult.Err == nil {
+				messageResult.Partition = saramaMessage.Partition
+				messageResult.Offset = saramaMessage.Offset
+			}
 		}
 	}
-	return nil
 }
 
-func (c *producer) ProduceCustomSync(_ context.Context, message *CustomMessage) error {
-	produceMessage, err := c.getProduceMessageFromCustomMessage(message)
+func (c *producer) ProduceAsync(ctx context.Context, message Message) error {
+	produceMessage, err := c.getProduceMessageFromMessage(ctx, message)
 	if err != nil {
 		return err
 	}
-	syncProducer, err := c.GetSyncProducer(message.Topic.Cluster)
+	asyncProducer, err := c.GetAsyncProducer(produceMessage.Topic.Cluster)
 	if err != nil {
 		return err
 	}
-	if _, _, err = syncProducer.SendMessage(produceMessage.ProducerMessage); err != nil {
-		return errors.Join(err, fmt.Errorf("produce custom sync err: %s, topic: %s, cluster: %s", err.Error(), produceMessage.Topic.Name, produceMessage.Topic.Cluster))
+	if err = asyncProducer.Send(ctx, produceMessage.ProducerMessage); err != nil {
+		return errors.Join(err, fmt.Errorf("produce async err: %s, topic: %s, cluster: %s", err.Error(), produceMessage.Topic.Name, produceMessage.Topic.Cluster))
 	}
 	return nil
 }
 
-func (c *producer) ProduceCustomSyncBulk(_ context.Context, messages []*CustomMessage, size int) error {
+func (c *producer) ProduceAsyncBulk(ctx context.Context, messages []Message) error {
 	mappedProducerMessages := make([]*producerMessage, 0, len(messages))
 	for _, message := range messages {
-		saramaProduceMessage, err := c.getProduceMessageFromCustomMessage(message)
+		produceMessage, err := c.getProduceMessageFromMessage(ctx, message)
 		if err != nil {
 			return err
 		}
-		mappedProducerMessages = append(mappedProducerMessages, saramaProduceMessage)
-	}
-	slicedProducerMessages, err := c.splitAndSliceKafKaMessages(mappedProducerMessages, size)
-	if err != nil {
-		return err
+		mappedProducerMessages = append(mappedProducerMessages, produceMessage)
 	}
-	for _, producerMessages := range slicedProducerMessages {
-		topic := producerMessages[0].Topic.Name
-		clusterName := producerMessages[0].Topic.Cluster
-		syncProducer, err := c.GetSyncProducer(clusterName)
+	for _, produceMessage := range mappedProducerMessages {
+		asyncProducer, err := c.GetAsyncProducer(produceMessage.Topic.Cluster)
 		if err != nil {
 			return err
 		}
-		saramaMessages := make([]*ProducerMessage, 0, len(producerMessages))
-		for _, producerMessage := range producerMessages {
-			saramaMessages = append(saramaMessages, producerMessage.ProducerMessage)
-		}
-		if err = syncProducer.SendMessages(saramaMessages); err != nil {
-			return errors.Join(err, fmt.Errorf("produce custom sync bulk err: %s, topic: %s, cluster: %s", err.Error(), topic, clusterName))
+		if err = asyncProducer.Send(ctx, produceMessage.ProducerMessage); err != nil {
+			return errors.Join(err, fmt.Errorf("produce async bulk err: %s, topic: %s, cluster: %s", err.Error(), produceMessage.Topic.Name, produceMessage.Topic.Cluster))
 		}
 	}
 	return nil
 }
 
-func (c *producer) getProduceMessageFromCustomMessage(message *CustomMessage) (*producerMessage, error) {
+// Close flushes the async producers, messages already accepted by ProduceAsync are still delivered to the handlers.
+func (c *producer) Close() error {
+	var closeErr error
+	for _, asyncProducer := range c.asyncProducerMap {
+		if err := asyncProducer.Close(); err != nil {
+			closeErr = errors.Join(closeErr, err)
+		}
+	}
+	return closeErr
+}
+
+func (c *producer) getProduceMessageFromCustomMessage(ctx context.Context, message *CustomMessage) (*producerMessage, error) {
 	saramaProduceMessage, err := c.mapToProducerMessage(message.Topic.Name, message.Key, message.Body)
 	if err != nil {
 		return nil, err
 	}
+	if saramaProduceMessage.Headers, err = mapMessageHeaders(message.Headers); err != nil {
+		return nil, err
+	}
+	applyProducerInterceptors(ctx, saramaProduceMessage, c.interceptors)
 	return &producerMessage{
 		Message:         nil,
 		ProducerMessage: saramaProduceMessage,
@@ -159,7 +268,7 @@ func (c *producer) getProduceMessageFromCustomMessage(message *CustomMessage) (*
 	}, nil
 }
 
-func (c *producer) getProduceMessageFromMessage(message Message) (*producerMessage, error) {
+func (c *producer) getProduceMessageFromMessage(ctx context.Context, message Message) (*producerMessage, error) {
 	producerTopic, err := c.GetProducerTopic(message.GetConfigName())
 	if err != nil {
 		return nil, err
@@ -168,6 +277,7 @@ func (c *producer) getProduceMessageFromMessage(message Message) (*producerMessa
 	if err != nil {
 		return nil, err
 	}
+	applyProducerInterceptors(ctx, saramaProducerMessage, c.interceptors)
 	return &producerMessage{
 		Message:         message,
 		Topic:           producerTopic,
@@ -0,0 +1,88 @@
+package kafka
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
+	"sync"
+)
+
+type AsyncProducer interface {
+	Send(ctx context.Context, message *ProducerMessage) error
+	Close() error
+}
+
+// asyncProducer bounds the messages waiting for an ack, Send blocks once the limit is reached.
+type asyncProducer struct {
+	mutex          sync.RWMutex
+	producer       sarama.AsyncProducer
+	inFlight       chan struct{}
+	successHandler func(message *ProducerMessage)
+	errorHandler   func(err *ProducerError)
+	waitGroup      sync.WaitGroup
+	closed         bool
+}
+
+func NewAsyncProducer(
+	producer sarama.AsyncProducer,
+	maxInFlight int,
+	successHandler func(message *ProducerMessage),
+	errorHandler func(err *ProducerError),
+) AsyncProducer {
+	p := &asyncProducer{
+		producer:       producer,
+		inFlight:       make(chan struct{}, maxInFlight),
+		successHandler: successHandler,
+		errorHandler:   errorHandler,
+	}
+	p.waitGroup.Add(2)
+	go p.handleSuccesses()
+	go p.handleErrors()
+	return p
+}
+
+func (p *asyncProducer) Send(ctx context.Context, message *ProducerMessage) error {
+	select {
+	case p.inFlight <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.closed {
+		<-p.inFlight
+		return custom_error.NewErrWithArgs("kafka async producer is closed, topic: %s", message.Topic)
+	}
+	p.producer.Input() <- message
+	return nil
+}
+
+// Close stops accepting messages and waits until every buffered message is acked and passed to the handlers.
+func (p *asyncProducer) Close() error {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.producer.AsyncClose()
+	p.mutex.Unlock()
+	p.waitGroup.Wait()
+	return nil
+}
+
+func (p *asyncProducer) handleSuccesses() {
+	defer p.waitGroup.Done()
+	for message := range p.producer.Successes() {
+		<-p.inFlight
+		p.successHandler(message)
+	}
+}
+
+func (p *asyncProducer) handleErrors() {
+	defer p.waitGroup.Done()
+	for err := range p.producer.Errors() {
+		<-p.inFlight
+		p.errorHandler(err)
+	}
+}
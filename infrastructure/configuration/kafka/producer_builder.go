@@ -9,6 +9,7 @@ type producerBuilder struct {
 	successHandler   func(message *ProducerMessage)
 	errorHandler     func(err *ProducerError)
 	topicConfigMap   ProducerTopicConfigMap
+	interceptors     []ProducerInterceptor
 }
 
 func NewProducerBuilder(clusterConfigMap ClusterConfigMap) *producerBuilder {
@@ -28,8 +29,24 @@ func NewProducerBuilderWithConfig(clusterConfigMap ClusterConfigMap, topicConfig
 	}
 }
 
+func (p *producerBuilder) WithSuccessHandler(successHandler func(message *ProducerMessage)) *producerBuilder {
+	p.successHandler = successHandler
+	return p
+}
+
+func (p *producerBuilder) WithErrorHandler(errorHandler func(err *ProducerError)) *producerBuilder {
+	p.errorHandler = errorHandler
+	return p
+}
+
+func (p *producerBuilder) WithInterceptors(interceptors ...ProducerInterceptor) *producerBuilder {
+	p.interceptors = append(p.interceptors, interceptors...)
+	return p
+}
+
 func (p *producerBuilder) Initialize() (Producer, error) {
 	syncProducerMap := make(map[string]SyncProducer)
+	asyncProducerMap := make(map[string]AsyncProducer)
 	for cluster := range p.clusterConfigMap {
 		clusterConfig, err := p.clusterConfigMap.GetConfigWithDefault(cluster)
 		if err != nil {
@@ -44,6 +61,11 @@ func (p *producerBuilder) Initialize() (Producer, error) {
 			return nil, err
 		}
 		syncProducerMap[cluster] = syncProducer
+		saramaAsyncProducer, err := sarama.NewAsyncProducerFromClient(client)
+		if err != nil {
+			return nil, err
+		}
+		asyncProducerMap[cluster] = NewAsyncProducer(saramaAsyncProducer, clusterConfig.ProducerConfig.MaxInFlight, p.successHandler, p.errorHandler)
 	}
-	return NewProducer(syncProducerMap, p.topicConfigMap)
+	return NewProducer(syncProducerMap, asyncProducerMap, p.topicConfigMap, p.interceptors...)
 }
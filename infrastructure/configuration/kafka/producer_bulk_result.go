@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type BulkProduceMessageResult struct {
+	Index     int
+	Topic     string
+	Cluster   string
+	Partition int32
+	Offset    int64
+	Err       error
+}
+
+type BulkProduceResult struct {
+	Results []*BulkProduceMessageResult
+}
+
+func (r *BulkProduceResult) Failed() []*BulkProduceMessageResult {
+	failed := make([]*BulkProduceMessageResult, 0)
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+func (r *BulkProduceResult) Err() error {
+	var joinedErr error
+	for _, result := range r.Failed() {
+		joinedErr = errors.Join(joinedErr, fmt.Errorf("produce sync bulk err: %s, index: %d, topic: %s, cluster: %s", result.Err.Error(), result.Index, result.Topic, result.Cluster))
+	}
+	return joinedErr
+}
+
+type BulkProduceOption func(options *bulkProduceOptions)
+
+type bulkProduceOptions struct {
+	retryAttempts int
+	retryBackoff  time.Duration
+}
+
+// WithBulkRetry sends only the failed messages again, the backoff doubles after every attempt.
+func WithBulkRetry(attempts int, backoff time.Duration) BulkProduceOption {
+	return func(options *bulkProduceOptions) {
+		options.retryAttempts = attempts
+		options.retryBackoff = backoff
+	}
+}
@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"presentation-advert-consumer/infrastructure/configuration/custom_json"
+	"presentation-advert-consumer/util"
+)
+
+type ProducerInterceptor func(ctx context.Context, message *ProducerMessage)
+
+func DefaultProducerInterceptors(serviceName string) []ProducerInterceptor {
+	return []ProducerInterceptor{
+		CorrelationIdProducerInterceptor,
+		TraceContextProducerInterceptor,
+		SourceServiceProducerInterceptor(serviceName),
+	}
+}
+
+func CorrelationIdProducerInterceptor(ctx context.Context, message *ProducerMessage) {
+	if correlationId := GetHeaderFromContext[string](ctx, CorrelationIdKey); correlationId != "" {
+		setProducerHeaderIfAbsent(message, CorrelationIdKey, util.ToByte(correlationId))
+	}
+}
+
+func TraceContextProducerInterceptor(ctx context.Context, message *ProducerMessage) {
+	for _, key := range []ContextKey{TraceParentKey, TraceStateKey} {
+		if value := GetHeaderFromContext[string](ctx, key); value != "" {
+			setProducerHeaderIfAbsent(message, key, util.ToByte(value))
+		}
+	}
+}
+
+// SourceServiceProducerInterceptor keeps the source service of the consumed message, the service name is used when the context has none.
+func SourceServiceProducerInterceptor(serviceName string) ProducerInterceptor {
+	return func(ctx context.Context, message *ProducerMessage) {
+		sourceService := GetHeaderFromContext[string](ctx, SourceServiceKey)
+		if sourceService == "" {
+			sourceService = serviceName
+		}
+		if sourceService != "" {
+			setProducerHeaderIfAbsent(message, SourceServiceKey, util.ToByte(sourceService))
+		}
+	}
+}
+
+func applyProducerInterceptors(ctx context.Context, message *ProducerMessage, interceptors []ProducerInterceptor) {
+	for _, interceptor := range interceptors {
+		interceptor(ctx, message)
+	}
+}
+
+func setProducerHeaderIfAbsent(message *ProducerMessage, key ContextKey, value []byte) {
+	for _, header := range message.Headers {
+		if string(header.Key) == key.String() {
+			return
+		}
+	}
+	message.Headers = append(message.Headers, sarama.RecordHeader{Key: util.ToByte(key.String()), Value: value})
+}
+
+func mapMessageHeaders(headers []MessageHeader) ([]sarama.RecordHeader, error) {
+	recordHeaders := make([]sarama.RecordHeader, 0, len(headers))
+	for _, header := range headers {
+		var value []byte
+		switch headerValue := header.Value.(type) {
+		case []byte:
+			value = headerValue
+		case string:
+			value = util.ToByte(headerValue)
+		default:
+			bytes, err := custom_json.Marshal(headerValue)
+			if err != nil {
+				return nil, err
+			}
+			value = bytes
+		}
+		recordHeaders = append(recordHeaders, sarama.RecordHeader{Key: util.ToByte(header.Key), Value: value})
+	}
+	return recordHeaders, nil
+}
@@ -0,0 +1,164 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/sarama"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// fakeSyncProducer fails the messages of a key for the given number of sends and records every sent message.
+type fakeSyncProducer struct {
+	mutex    sync.Mutex
+	failures map[string]int
+	sent     []*ProducerMessage
+}
+
+func newFakeSyncProducer(failures map[string]int) *fakeSyncProducer {
+	if failures == nil {
+		failures = make(map[string]int)
+	}
+	return &fakeSyncProducer{failures: failures}
+}
+
+func (p *fakeSyncProducer) SendMessage(message *ProducerMessage) (int32, int64, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err := p.send(message); err != nil {
+		return 0, 0, err
+	}
+	return message.Partition, message.Offset, nil
+}
+
+func (p *fakeSyncProducer) SendMessages(messages []*ProducerMessage) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var producerErrors sarama.ProducerErrors
+	for _, message := range messages {
+		if err := p.send(message); err != nil {
+			producerErrors = append(producerErrors, &ProducerError{Msg: message, Err: err})
+		}
+	}
+	if len(producerErrors) > 0 {
+		return producerErrors
+	}
+	return nil
+}
+
+func (p *fakeSyncProducer) send(message *ProducerMessage) error {
+	p.sent = append(p.sent, message)
+	key := encodedString(message.Key)
+	if p.failures[key] > 0 {
+		p.failures[key]--
+		return errors.New("send failed, key: " + key)
+	}
+	message.Offset = int64(len(p.sent))
+	return nil
+}
+
+func (p *fakeSyncProducer) sentKeys() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	keys := make([]string, 0, len(p.sent))
+	for _, message := range p.sent {
+		keys = append(keys, encodedString(message.Key))
+	}
+	return keys
+}
+
+func (p *fakeSyncProducer) sentTopics() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	topics := make([]string, 0, len(p.sent))
+	for _, message := range p.sent {
+		topics = append(topics, message.Topic)
+	}
+	return topics
+}
+
+func encodedString(encoder sarama.Encoder) string {
+	if encoder == nil {
+		return ""
+	}
+	bytes, _ := encoder.Encode()
+	return string(bytes)
+}
+
+func TestProducer_ProduceCustomSyncBulk(t *testing.T) {
+	tests := []struct {
+		name           string
+		keys           []string
+		size           int
+		failures       map[string]int
+		options        []BulkProduceOption
+		expectedFailed []int
+		expectedSent   []string
+	}{
+		{
+			name:           "all sent",
+			keys:           []string{"a", "b", "c"},
+			size:           2,
+			expectedFailed: []int{},
+			expectedSent:   []string{"a", "b", "c"},
+		},
+		{
+			name:           "failed without retry",
+			keys:           []string{"a", "b", "c"},
+			size:           3,
+			failures:       map[string]int{"b": 1},
+			expectedFailed: []int{1},
+			expectedSent:   []string{"a", "b", "c"},
+		},
+		{
+			name:           "only failed messages are retried",
+			keys:           []string{"a", "b", "c"},
+			size:           3,
+			failures:       map[string]int{"b": 1, "c": 2},
+			options:        []BulkProduceOption{WithBulkRetry(2, 0)},
+			expectedFailed: []int{},
+			expectedSent:   []string{"a", "b", "c", "b", "c", "c"},
+		},
+		{
+			name:           "retry attempts exhausted",
+			keys:           []string{"a", "b", "c"},
+			size:           1,
+			failures:       map[string]int{"a": 3},
+			options:        []BulkProduceOption{WithBulkRetry(1, 0)},
+			expectedFailed: []int{0},
+			expectedSent:   []string{"a", "b", "c", "a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			syncProducer := newFakeSyncProducer(test.failures)
+			producer, _ := NewProducer(map[string]SyncProducer{"local": syncProducer}, nil, nil)
+			messages := make([]*CustomMessage, 0, len(test.keys))
+			for _, key := range test.keys {
+				messages = append(messages, &CustomMessage{Key: key, Body: key, Topic: &ProducerTopic{Name: "topic", Cluster: "local"}})
+			}
+
+			result, err := producer.ProduceCustomSyncBulk(context.Background(), messages, test.size, test.options...)
+
+			failed := make([]int, 0)
+			for _, messageResult := range result.Failed() {
+				failed = append(failed, messageResult.Index)
+			}
+			if !slices.Equal(failed, test.expectedFailed) {
+				t.Errorf("failed indexes = %v, expected %v", failed, test.expectedFailed)
+			}
+			if (err != nil) != (len(test.expectedFailed) > 0) {
+				t.Errorf("err = %v, expected failed indexes %v", err, test.expectedFailed)
+			}
+			if sent := syncProducer.sentKeys(); !slices.Equal(sent, test.expectedSent) {
+				t.Errorf("sent keys = %v, expected %v", sent, test.expectedSent)
+			}
+			for _, messageResult := range result.Results {
+				if messageResult.Err == nil && messageResult.Offset == 0 {
+					t.Errorf("offset of sent message %d is not set", messageResult.Index)
+				}
+			}
+		})
+	}
+}
@@ -11,9 +11,10 @@ type Message interface {
 }
 
 type CustomMessage struct {
-	Key   string
-	Body  interface{}
-	Topic *ProducerTopic
+	Key     string
+	Body    interface{}
+	Topic   *ProducerTopic
+	Headers []MessageHeader
 }
 
 type ProducerTopicConfigMap map[string]*ProducerTopic
@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"fmt"
 	"github.com/IBM/sarama"
 	"github.com/rcrowley/go-metrics"
 	"presentation-advert-consumer/infrastructure/configuration/custom_error"
@@ -65,7 +66,8 @@ func getSaramaConfig(clusterConfig *ClusterConfig, consumerTopicConfig *Consumer
 		config.Consumer.Fetch.Default = consumerTopicConfig.FetchMaxBytes
 		config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
 		config.Consumer.Group.Rebalance.Timeout = consumerTopicConfig.RebalanceTimeout
-		config.Consumer.Offsets.AutoCommit.Enable = true
+		config.Consumer.IsolationLevel = consumerTopicConfig.IsolationLevel.GetSaramaIsolationLevel()
+		config.Consumer.Offsets.AutoCommit.Enable = consumerTopicConfig.CommitMode != CommitModeExactlyOnce
 		config.Consumer.Offsets.AutoCommit.Interval = 1 * time.Second
 	} else {
 		config.Consumer.Return.Errors = true
@@ -78,6 +80,21 @@ func getSaramaConfig(clusterConfig *ClusterConfig, consumerTopicConfig *Consumer
 	return config, nil
 }
 
+func getSaramaTransactionalProducerConfig(clusterConfig *ClusterConfig, consumerTopicConfig *ConsumerGroupConfig) (*sarama.Config, error) {
+	config, err := getSaramaConfig(clusterConfig, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		return nil, custom_error.NewErrWithArgs("exactly once commit mode requires kafka version 0.11 or later, group: %s", consumerTopicConfig.GroupId)
+	}
+	config.Producer.Idempotent = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Transaction.ID = fmt.Sprintf("%s.%s", consumerTopicConfig.GroupId, hostname)
+	config.Net.MaxOpenRequests = 1
+	return config, nil
+}
+
 func getSaramaErrorConfig(clusterConfig *ClusterConfig, consumerGroupConfig *ConsumerGroupErrorConfig) (*sarama.Config, error) {
 	v, err := sarama.ParseKafkaVersion(clusterConfig.Version)
 	if err != nil {
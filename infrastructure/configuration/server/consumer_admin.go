@@ -0,0 +1,115 @@
+package server
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
+	"presentation-advert-consumer/infrastructure/configuration/kafka"
+	"sort"
+)
+
+type consumerAdmin struct {
+	consumerGroups      map[string]kafka.ConsumerGroup
+	errorConsumerGroups map[string]kafka.ErrorConsumerGroup
+}
+
+type pauseConsumerGroupRequest struct {
+	Topic      string  `json:"topic"`
+	Partitions []int32 `json:"partitions"`
+}
+
+type consumerGroupPauseResponse struct {
+	GroupId string `json:"groupId"`
+	Paused  bool   `json:"paused"`
+}
+
+func RegisterConsumerAdmin(e *echo.Echo, consumerGroups map[string]kafka.ConsumerGroup, errorConsumerGroups map[string]kafka.ErrorConsumerGroup) {
+	admin := &consumerAdmin{
+		consumerGroups:      consumerGroups,
+		errorConsumerGroups: errorConsumerGroups,
+	}
+	e.GET("/admin/consumers", admin.getStates)
+	e.GET("/admin/consumers/:groupId", admin.getState)
+	e.GET("/admin/consumers/:groupId/pause", admin.getPauseStatus)
+	e.POST("/admin/consumers/:groupId/pause", admin.pause)
+	e.POST("/admin/consumers/:groupId/resume", admin.resume)
+}
+
+func (admin *consumerAdmin) getStates(c echo.Context) error {
+	states := make([]*kafka.ConsumerGroupState, 0, len(admin.consumerGroups)+len(admin.errorConsumerGroups))
+	for _, consumerGroup := range admin.consumerGroups {
+		states = append(states, consumerGroup.Snapshot())
+	}
+	for _, errorConsumerGroup := range admin.errorConsumerGroups {
+		states = append(states, errorConsumerGroup.Snapshot())
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].GroupId < states[j].GroupId
+	})
+	return c.JSON(http.StatusOK, states)
+}
+
+func (admin *consumerAdmin) getState(c echo.Context) error {
+	groupId := c.Param("groupId")
+	if consumerGroup, exists := admin.consumerGroups[groupId]; exists {
+		return c.JSON(http.StatusOK, consumerGroup.Snapshot())
+	}
+	if errorConsumerGroup, exists := admin.errorConsumerGroups[groupId]; exists {
+		return c.JSON(http.StatusOK, errorConsumerGroup.Snapshot())
+	}
+	return custom_error.NotFoundErrWithArgs("consumer group not found, groupId: %s", groupId)
+}
+
+func (admin *consumerAdmin) getPauseStatus(c echo.Context) error {
+	consumerGroup, err := admin.getConsumerGroup(c.Param("groupId"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, toConsumerGroupPauseResponse(consumerGroup))
+}
+
+func (admin *consumerAdmin) pause(c echo.Context) error {
+	consumerGroup, err := admin.getConsumerGroup(c.Param("groupId"))
+	if err != nil {
+		return err
+	}
+	var request pauseConsumerGroupRequest
+	if c.Request().ContentLength > 0 {
+		if err := c.Bind(&request); err != nil {
+			return custom_error.BadRequestErrWithArgs("pause request could not be read, err: %s", err.Error())
+		}
+	}
+	if len(request.Topic) == 0 {
+		consumerGroup.Pause()
+		return c.JSON(http.StatusOK, toConsumerGroupPauseResponse(consumerGroup))
+	}
+	if len(request.Partitions) == 0 {
+		return custom_error.BadRequestErrWithArgs("partitions required to pause topic: %s", request.Topic)
+	}
+	consumerGroup.PausePartitions(request.Topic, request.Partitions)
+	return c.JSON(http.StatusOK, toConsumerGroupPauseResponse(consumerGroup))
+}
+
+func (admin *consumerAdmin) resume(c echo.Context) error {
+	consumerGroup, err := admin.getConsumerGroup(c.Param("groupId"))
+	if err != nil {
+		return err
+	}
+	consumerGroup.Resume()
+	return c.JSON(http.StatusOK, toConsumerGroupPauseResponse(consumerGroup))
+}
+
+func (admin *consumerAdmin) getConsumerGroup(groupId string) (kafka.ConsumerGroup, error) {
+	consumerGroup, exists := admin.consumerGroups[groupId]
+	if !exists {
+		return nil, custom_error.NotFoundErrWithArgs("consumer group not found, groupId: %s", groupId)
+	}
+	return consumerGroup, nil
+}
+
+func toConsumerGroupPauseResponse(consumerGroup kafka.ConsumerGroup) *consumerGroupPauseResponse {
+	return &consumerGroupPauseResponse{
+		GroupId: consumerGroup.GetGroupId(),
+		Paused:  consumerGroup.IsPaused(),
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
+	"presentation-advert-consumer/infrastructure/configuration/kafka"
+	"strconv"
+	"time"
+)
+
+type errorTopicAdmin struct {
+	errorConsumerGroups map[string]kafka.ErrorConsumerGroup
+}
+
+type errorMessagesRequest struct {
+	Messages []*kafka.ErrorMessageCoordinate `json:"messages"`
+}
+
+type errorMessagesSkipResponse struct {
+	Skipped int `json:"skipped"`
+}
+
+type errorConsumerRunResponse struct {
+	GroupId string `json:"groupId"`
+	Started bool   `json:"started"`
+}
+
+func RegisterErrorTopicAdmin(e *echo.Echo, errorConsumerGroups map[string]kafka.ErrorConsumerGroup) {
+	admin := &errorTopicAdmin{
+		errorConsumerGroups: errorConsumerGroups,
+	}
+	e.GET("/admin/error-consumers/:groupId/messages", admin.list)
+	e.POST("/admin/error-consumers/:groupId/messages/replay", admin.replay)
+	e.POST("/admin/error-consumers/:groupId/messages/skip", admin.skip)
+	e.POST("/admin/error-consumers/:groupId/messages/replay-matching", admin.replayMatching)
+	e.POST("/admin/error-consumers/:groupId/runs", admin.triggerRun)
+	e.GET("/admin/error-consumers/:groupId/runs", admin.getRunReports)
+}
+
+func (admin *errorTopicAdmin) list(c echo.Context) error {
+	errorConsumerGroup, err := admin.getErrorConsumerGroup(c.Param("groupId"))
+	if err != nil {
+		return err
+	}
+	filter, err := bindErrorMessageFilter(c)
+	if err != nil {
+		return err
+	}
+	messages, err := errorConsumerGroup.ListErrorMessages(c.Request().Context(), filter)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, messages)
+}
+
+func (admin *errorTopicAdmin) replay(c echo.Context) error {
+	errorConsumerGroup, err := admin.getErrorConsumerGroup(c.Param("groupId"))
+	if err != nil {
+		return err
+	}
+	var request errorMessagesRequest
+	if err := c.Bind(&request); err != nil {
+		return custom_error.BadRequestErrWithArgs("replay request could not be read, err: %s", err.Error())
+	}
+	result, err := errorConsumerGroup.ReplayErrorMessages(c.Request().Context(), request.Messages)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func (admin *errorTopicAdmin) skip(c echo.Context) error {
+	errorConsumerGroup, err := admin.getErrorConsumerGroup(c.Param("groupId"))
+	if err != nil {
+		return err
+	}
+	var request errorMessagesRequest
+	if err := c.Bind(&request); err != nil {
+		return custom_error.BadRequestErrWithArgs("skip request could not be read, err: %s", err.Error())
+	}
+	if err := errorConsumerGroup.SkipErrorMessages(request.Messages); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, &errorMessagesSkipResponse{Skipped: len(request.Messages)})
+}
+
+func (admin *errorTopicAdmin) replayMatching(c echo.Context) error {
+	errorConsumerGroup, err := admin.getErrorConsumerGroup(c.Param("groupId"))
+	if err != nil {
+		return err
+	}
+	filter, err := bindErrorMessageFilter(c)
+	if err != nil {
+		return err
+	}
+	dryRun := true
+	if value := c.QueryParam("dryRun"); len(value) > 0 {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return custom_error.BadRequestErrWithArgs("dryRun should be true or false, value: %s", value)
+		}
+	}
+	result, err := errorConsumerGroup.ReplayMatchingErrorMessages(c.Request().Context(), filter, dryRun)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func (admin *errorTopicAdmin) triggerRun(c echo.Context) error {
+	errorConsumerGroup, err := admin.getErrorConsumerGroup(c.Param("groupId"))
+	if err != nil {
+		return err
+	}
+	if err := errorConsumerGroup.TriggerRun(); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusAccepted, &errorConsumerRunResponse{GroupId: errorConsumerGroup.GetGroupId(), Started: true})
+}
+
+func (admin *errorTopicAdmin) getRunReports(c echo.Context) error {
+	errorConsumerGroup, err := admin.getErrorConsumerGroup(c.Param("groupId"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, errorConsumerGroup.GetRunReports())
+}
+
+func (admin *errorTopicAdmin) getErrorConsumerGroup(groupId string) (kafka.ErrorConsumerGroup, error) {
+	errorConsumerGroup, exists := admin.errorConsumerGroups[groupId]
+	if !exists {
+		return nil, custom_error.NotFoundErrWithArgs("error consumer group not found, groupId: %s", groupId)
+	}
+	return errorConsumerGroup, nil
+}
+
+func bindErrorMessageFilter(c echo.Context) (*kafka.ErrorMessageFilter, error) {
+	filter := &kafka.ErrorMessageFilter{
+		Topic:         c.QueryParam("topic"),
+		Key:           c.QueryParam("key"),
+		TargetTopic:   c.QueryParam("targetTopic"),
+		ErrorContains: c.QueryParam("error"),
+	}
+	var err error
+	if filter.From, err = parseTimeQueryParam(c, "from"); err != nil {
+		return nil, err
+	}
+	if filter.To, err = parseTimeQueryParam(c, "to"); err != nil {
+		return nil, err
+	}
+	if value := c.QueryParam("limit"); len(value) > 0 {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			return nil, custom_error.BadRequestErrWithArgs("limit should be a number, value: %s", value)
+		}
+	}
+	return filter, nil
+}
+
+func parseTimeQueryParam(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, custom_error.BadRequestErrWithArgs("%s should be in RFC3339 format, value: %s", name, value)
+	}
+	return parsed, nil
+}
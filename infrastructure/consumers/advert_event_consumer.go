@@ -4,6 +4,7 @@ import (
 	"context"
 	"presentation-advert-consumer/application/commands"
 	"presentation-advert-consumer/application/handlers"
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
 	"presentation-advert-consumer/infrastructure/configuration/custom_json"
 	"presentation-advert-consumer/infrastructure/configuration/kafka"
 	"presentation-advert-consumer/infrastructure/configuration/log"
@@ -12,19 +13,80 @@ import (
 
 type advertEventConsumer struct {
 	commandHandler *handlers.CommandHandler
+	dispatcher     *EventTypeDispatcher
 }
 
-func NewAdvertEventConsumer(commandHandler *handlers.CommandHandler) kafka.Consumer {
+func NewAdvertEventConsumer(commandHandler *handlers.CommandHandler, dispatcher *EventTypeDispatcher) kafka.Consumer {
 	return &advertEventConsumer{
 		commandHandler: commandHandler,
+		dispatcher:     dispatcher,
+	}
+}
+
+func NewAdvertEventBatchConsumer(commandHandler *handlers.CommandHandler, dispatcher *EventTypeDispatcher) kafka.BatchConsumer {
+	return &advertEventConsumer{
+		commandHandler: commandHandler,
+		dispatcher:     dispatcher,
 	}
 }
 
 func (consumer *advertEventConsumer) Consume(ctx context.Context, msg *kafka.ConsumerMessage) error {
 	var event model.AdvertEvent
 	if err := custom_json.Unmarshal(msg.Value, &event); err != nil {
-		return err
+		return custom_error.NewPermanentErr(err)
+	}
+	switch consumer.dispatcher.Resolve(event.Type) {
+	case EventActionIndex:
+		return consumer.commandHandler.IndexAdvert.Handle(ctx, &commands.IndexAdvert{Id: event.Id})
+	case EventActionDelete:
+		return consumer.commandHandler.DeleteAdvert.Handle(ctx, &commands.DeleteAdvert{Id: event.Id})
+	default:
+		return nil
+	}
+}
+
+func (consumer *advertEventConsumer) ConsumeBatch(ctx context.Context, msgs []*kafka.ConsumerMessage) error {
+	batchErr := kafka.NewBatchError()
+	idMessagesMap := make(map[int64][]*kafka.ConsumerMessage)
+	idActionMap := make(map[int64]EventAction)
+	ids := make([]int64, 0, len(msgs))
+	for _, msg := range msgs {
+		var event model.AdvertEvent
+		if err := custom_json.Unmarshal(msg.Value, &event); err != nil {
+			batchErr.Add(msg, custom_error.NewPermanentErr(err))
+			continue
+		}
+		action := consumer.dispatcher.Resolve(event.Type)
+		if action == EventActionSkip {
+			continue
+		}
+		if _, exists := idMessagesMap[event.Id]; !exists {
+			ids = append(ids, event.Id)
+		}
+		idMessagesMap[event.Id] = append(idMessagesMap[event.Id], msg)
+		idActionMap[event.Id] = action
+	}
+	indexIds := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if idActionMap[id] == EventActionDelete {
+			if err := consumer.commandHandler.DeleteAdvert.Handle(ctx, &commands.DeleteAdvert{Id: id}); err != nil {
+				for _, msg := range idMessagesMap[id] {
+					batchErr.Add(msg, err)
+				}
+			}
+			continue
+		}
+		indexIds = append(indexIds, id)
+	}
+	log.Infof("Consumed advert event batch, size: %d, distinct ids: %d, indexed ids: %d", len(msgs), len(ids), len(indexIds))
+	failedIds := consumer.commandHandler.IndexAdverts.Handle(ctx, &commands.IndexAdverts{Ids: indexIds})
+	for id, err := range failedIds {
+		for _, msg := range idMessagesMap[id] {
+			batchErr.Add(msg, err)
+		}
+	}
+	if batchErr.HasErrors() {
+		return batchErr
 	}
-	log.Infof("Consumed advert event, id: %d, type: %s", event.Id, event.Type)
-	return consumer.commandHandler.IndexAdvert.Handle(ctx, &commands.IndexAdvert{Id: event.Id})
+	return nil
 }
@@ -5,33 +5,40 @@ import (
 	"presentation-advert-consumer/application/cacheservice"
 	"presentation-advert-consumer/application/commands"
 	"presentation-advert-consumer/application/handlers"
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
 	"presentation-advert-consumer/infrastructure/configuration/custom_json"
 	"presentation-advert-consumer/infrastructure/configuration/kafka"
-	"presentation-advert-consumer/infrastructure/configuration/log"
 	"presentation-advert-consumer/infrastructure/consumers/model"
 )
 
 type categoryEventConsumer struct {
 	commandHandler       *handlers.CommandHandler
 	categoryCacheService cacheservice.CategoryCacheService
+	dispatcher           *EventTypeDispatcher
 }
 
-func NewCategoryEventConsumer(commandHandler *handlers.CommandHandler, categoryCacheService cacheservice.CategoryCacheService) kafka.Consumer {
+func NewCategoryEventConsumer(commandHandler *handlers.CommandHandler, categoryCacheService cacheservice.CategoryCacheService, dispatcher *EventTypeDispatcher) kafka.Consumer {
 	return &categoryEventConsumer{
 		commandHandler:       commandHandler,
 		categoryCacheService: categoryCacheService,
+		dispatcher:           dispatcher,
 	}
 }
 
 func (consumer *categoryEventConsumer) Consume(ctx context.Context, msg *kafka.ConsumerMessage) error {
 	var event model.CategoryEvent
 	if err := custom_json.Unmarshal(msg.Value, &event); err != nil {
-		return err
+		return custom_error.NewPermanentErr(err)
 	}
-	log.Infof("Consumed category event, id: %d, type: %s", event.Id, event.Type)
-	err := consumer.commandHandler.IndexCategory.Handle(ctx, &commands.IndexCategory{Id: event.Id})
-	if err != nil {
-		return err
+	switch consumer.dispatcher.Resolve(event.Type) {
+	case EventActionIndex:
+		if err := consumer.commandHandler.IndexCategory.Handle(ctx, &commands.IndexCategory{Id: event.Id}); err != nil {
+			return err
+		}
+		return consumer.categoryCacheService.InvalidateById(ctx, event.Id)
+	case EventActionDelete:
+		return consumer.commandHandler.DeleteCategory.Handle(ctx, &commands.DeleteCategory{Id: event.Id})
+	default:
+		return nil
 	}
-	return consumer.categoryCacheService.InvalidateById(ctx, event.Id)
 }
@@ -0,0 +1,63 @@
+package consumers
+
+import (
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
+	"presentation-advert-consumer/infrastructure/configuration/kafka"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"strings"
+)
+
+type EventAction string
+
+const (
+	EventActionIndex  EventAction = "index"
+	EventActionDelete EventAction = "delete"
+	EventActionSkip   EventAction = "skip"
+)
+
+var defaultEventActions = map[string]EventAction{
+	"created": EventActionIndex,
+	"updated": EventActionIndex,
+	"deleted": EventActionDelete,
+}
+
+type EventTypeDispatcher struct {
+	configName string
+	actions    map[string]EventAction
+}
+
+func NewEventTypeDispatcher(consumerConfigMap kafka.ConsumerGroupConfigMap, configName string) (*EventTypeDispatcher, error) {
+	config, err := consumerConfigMap.GetConfigWithDefault(configName)
+	if err != nil {
+		return nil, err
+	}
+	actions := make(map[string]EventAction, len(defaultEventActions)+len(config.EventTypes))
+	for eventType, action := range defaultEventActions {
+		actions[eventType] = action
+	}
+	for eventType, action := range config.EventTypes {
+		eventAction := EventAction(strings.ToLower(action))
+		if eventAction != EventActionIndex && eventAction != EventActionDelete && eventAction != EventActionSkip {
+			return nil, custom_error.NewErrWithArgs("event type action should be index, delete or skip, config name: %s, event type: %s", configName, eventType)
+		}
+		actions[strings.ToLower(eventType)] = eventAction
+	}
+	return &EventTypeDispatcher{
+		configName: configName,
+		actions:    actions,
+	}, nil
+}
+
+func (dispatcher *EventTypeDispatcher) Resolve(eventType string) EventAction {
+	action, exists := dispatcher.actions[strings.ToLower(eventType)]
+	if !exists {
+		log.Infof("Skipped unknown event, consumer: %s, type: %s", dispatcher.configName, eventType)
+		skippedEventCounter.WithLabelValues(dispatcher.configName, unknownEventTypeLabel).Inc()
+		return EventActionSkip
+	}
+	if action == EventActionSkip {
+		log.Infof("Skipped event, consumer: %s, type: %s", dispatcher.configName, eventType)
+		skippedEventCounter.WithLabelValues(dispatcher.configName, strings.ToLower(eventType)).Inc()
+	}
+	return action
+}
@@ -0,0 +1,14 @@
+package consumers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// unknownEventTypeLabel keeps the type label bounded to the configured event types.
+const unknownEventTypeLabel = "unknown"
+
+var skippedEventCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "consumer_skipped_events_total",
+	Help: "Number of consumed events skipped because their type is not mapped to a command.",
+}, []string{"consumer", "type"})
@@ -5,6 +5,7 @@ import (
 	"presentation-advert-consumer/application/client"
 	"presentation-advert-consumer/application/handlers"
 	"presentation-advert-consumer/application/handlers/command_handlers"
+	"presentation-advert-consumer/application/publisher"
 	"presentation-advert-consumer/application/tracers"
 	"presentation-advert-consumer/infrastructure/repository"
 	infraTracers "presentation-advert-consumer/infrastructure/tracers"
@@ -14,7 +15,9 @@ func InitializeCommandHandler(
 	advertApiClient client.AdvertApiClient,
 	categoryRepository *repository.CategoryElasticRepository,
 	advertRepository *repository.AdvertElasticRepository,
+	unpublishedFieldsRepository *repository.UnpublishedFieldsElasticRepository,
 	categoryCacheService cacheservice.CategoryCacheService,
+	eventPublisher publisher.IndexedEventPublisher,
 ) (*handlers.CommandHandler, error) {
 	tracer := []tracers.Tracer{
 		infraTracers.NewOtelTracer(),
@@ -23,11 +26,29 @@ func InitializeCommandHandler(
 	commandHandler.IndexCategory = handlers.NewCommandHandlerDecorator(command_handlers.NewIndexCategoryCommandHandler(
 		advertApiClient,
 		categoryRepository,
+		unpublishedFieldsRepository,
+		eventPublisher,
 	), tracer)
 	commandHandler.IndexAdvert = handlers.NewCommandHandlerDecorator(command_handlers.NewIndexAdvertCommandHandler(
 		advertApiClient,
 		advertRepository,
+		unpublishedFieldsRepository,
 		categoryCacheService,
+		eventPublisher,
+	), tracer)
+	commandHandler.IndexAdverts = handlers.NewCommandHandlerDecorator(command_handlers.NewIndexAdvertsCommandHandler(
+		advertApiClient,
+		advertRepository,
+		unpublishedFieldsRepository,
+		categoryCacheService,
+		eventPublisher,
+	), tracer)
+	commandHandler.DeleteCategory = handlers.NewCommandHandlerDecorator(command_handlers.NewDeleteCategoryCommandHandler(
+		categoryRepository,
+		categoryCacheService,
+	), tracer)
+	commandHandler.DeleteAdvert = handlers.NewCommandHandlerDecorator(command_handlers.NewDeleteAdvertCommandHandler(
+		advertRepository,
 	), tracer)
 	return commandHandler, nil
 }
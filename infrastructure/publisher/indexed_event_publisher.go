@@ -0,0 +1,99 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"presentation-advert-consumer/application/publisher"
+	"presentation-advert-consumer/infrastructure/configuration/kafka"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"presentation-advert-consumer/model/model_event"
+)
+
+type indexedEventPublisher struct {
+	producer                  kafka.Producer
+	advertIndexedConfigName   string
+	categoryIndexedConfigName string
+}
+
+func NewIndexedEventPublisher(producer kafka.Producer, config *Config) (publisher.IndexedEventPublisher, error) {
+	for _, configName := range []string{config.AdvertIndexedTopicConfigName, config.CategoryIndexedTopicConfigName} {
+		if _, err := producer.GetProducerTopic(configName); err != nil {
+			return nil, err
+		}
+	}
+	return &indexedEventPublisher{
+		producer:                  producer,
+		advertIndexedConfigName:   config.AdvertIndexedTopicConfigName,
+		categoryIndexedConfigName: config.CategoryIndexedTopicConfigName,
+	}, nil
+}
+
+func (p *indexedEventPublisher) PublishAdvertIndexed(ctx context.Context, event *model_event.AdvertIndexed) error {
+	if err := p.producer.ProduceSync(ctx, &advertIndexedMessage{AdvertIndexed: event, configName: p.advertIndexedConfigName}); err != nil {
+		log.Errorf("An error occurred when publishing advert indexed event, id: %d, err: %s", event.Id, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (p *indexedEventPublisher) PublishAdvertsIndexed(ctx context.Context, events []*model_event.AdvertIndexed) map[int64]error {
+	failedIds := make(map[int64]error)
+	if len(events) == 0 {
+		return failedIds
+	}
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		messages = append(messages, &advertIndexedMessage{AdvertIndexed: event, configName: p.advertIndexedConfigName})
+	}
+	result, err := p.producer.ProduceSyncBulk(ctx, messages, len(messages))
+	if err == nil {
+		return failedIds
+	}
+	if result == nil {
+		log.Errorf("An error occurred when publishing advert indexed events, count: %d, err: %s", len(events), err.Error())
+		for _, event := range events {
+			failedIds[event.Id] = err
+		}
+		return failedIds
+	}
+	for _, failed := range result.Failed() {
+		event := events[failed.Index]
+		log.Errorf("An error occurred when publishing advert indexed event, id: %d, err: %s", event.Id, failed.Err.Error())
+		failedIds[event.Id] = failed.Err
+	}
+	return failedIds
+}
+
+func (p *indexedEventPublisher) PublishCategoryIndexed(ctx context.Context, event *model_event.CategoryIndexed) error {
+	if err := p.producer.ProduceSync(ctx, &categoryIndexedMessage{CategoryIndexed: event, configName: p.categoryIndexedConfigName}); err != nil {
+		log.Errorf("An error occurred when publishing category indexed event, id: %d, err: %s", event.Id, err.Error())
+		return err
+	}
+	return nil
+}
+
+type advertIndexedMessage struct {
+	*model_event.AdvertIndexed
+	configName string
+}
+
+func (m *advertIndexedMessage) GetConfigName() string {
+	return m.configName
+}
+
+func (m *advertIndexedMessage) GetKey() string {
+	return fmt.Sprint(m.Id)
+}
+
+type categoryIndexedMessage struct {
+	*model_event.CategoryIndexed
+	configName string
+}
+
+func (m *categoryIndexedMessage) GetConfigName() string {
+	return m.configName
+}
+
+func (m *categoryIndexedMessage) GetKey() string {
+	return fmt.Sprint(m.Id)
+}
@@ -0,0 +1,6 @@
+package publisher
+
+type Config struct {
+	AdvertIndexedTopicConfigName   string `json:"advertIndexedTopicConfigName"`
+	CategoryIndexedTopicConfigName string `json:"categoryIndexedTopicConfigName"`
+}
@@ -35,10 +35,68 @@ func (repository *AdvertElasticRepository) Save(ctx context.Context, model *mode
 	return nil
 }
 
+func (repository *AdvertElasticRepository) SaveAll(ctx context.Context, models []*model_repository.Advert) map[int64]error {
+	failedIds := make(map[int64]error)
+	if len(models) == 0 {
+		return failedIds
+	}
+	documents := make([]*elastic.IndexDocument, 0, len(models))
+	modelIds := make(map[string]int64, len(models))
+	for _, model := range models {
+		id := fmt.Sprint(model.Id)
+		documents = append(documents, &elastic.IndexDocument{Id: id, Routing: id, Body: model})
+		modelIds[id] = model.Id
+	}
+	failedDocuments, err := repository.IndexDocuments(ctx, documents)
+	if err != nil {
+		log.Errorf("An error occurred when bulk indexing adverts, count: %d, err: %s", len(models), err.Error())
+		for _, model := range models {
+			failedIds[model.Id] = err
+		}
+		return failedIds
+	}
+	for documentId, err := range failedDocuments {
+		log.Errorf("An error occurred when bulk indexing advert, id: %s, err: %s", documentId, err.Error())
+		failedIds[modelIds[documentId]] = err
+	}
+	log.Infof("Bulk indexed adverts, count: %d, failed: %d", len(models)-len(failedIds), len(failedIds))
+	return failedIds
+}
+
+func (repository *AdvertElasticRepository) Delete(ctx context.Context, id int64) error {
+	documentId := fmt.Sprint(id)
+	err := repository.DeleteById(ctx, &elastic.DeleteDocument{Id: documentId, Routing: documentId})
+	if err != nil {
+		log.Errorf("An error occurred when deleting advert, id: %d, err: %s", id, err.Error())
+		return err
+	}
+	log.Infof("Deleted advert, id: %d", id)
+	return nil
+}
+
 func (repository *AdvertElasticRepository) GetById(ctx context.Context, id int64) (*model_repository.Advert, error) {
 	return repository.BaseGenericRepository.GetById(ctx, fmt.Sprint(id), "")
 }
 
+// GetByIds fetches the adverts in one request, adverts that are not indexed are left out of the result.
+func (repository *AdvertElasticRepository) GetByIds(ctx context.Context, ids []int64) (map[int64]*model_repository.Advert, error) {
+	documents := make([]*elastic.GetDocument, 0, len(ids))
+	for _, id := range ids {
+		documentId := fmt.Sprint(id)
+		documents = append(documents, &elastic.GetDocument{Id: documentId, Routing: documentId})
+	}
+	adverts, err := repository.BaseGenericRepository.GetByIds(ctx, documents)
+	if err != nil {
+		log.Errorf("An error occurred when getting adverts, count: %d, err: %s", len(ids), err.Error())
+		return nil, err
+	}
+	result := make(map[int64]*model_repository.Advert, len(adverts))
+	for _, advert := range adverts {
+		result[advert.Id] = advert
+	}
+	return result, nil
+}
+
 func mapToIdForAdvert(searchHit *elastic.SearchHit) (string, error) {
 	return searchHit.Id, nil
 }
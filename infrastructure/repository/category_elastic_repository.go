@@ -35,6 +35,17 @@ func (repository *CategoryElasticRepository) Save(ctx context.Context, model *mo
 	return nil
 }
 
+func (repository *CategoryElasticRepository) Delete(ctx context.Context, id int64) error {
+	documentId := fmt.Sprint(id)
+	err := repository.DeleteById(ctx, &elastic.DeleteDocument{Id: documentId, Routing: documentId})
+	if err != nil {
+		log.Errorf("An error occurred when deleting category, id: %d, err: %s", id, err.Error())
+		return err
+	}
+	log.Infof("Deleted category, id: %d", id)
+	return nil
+}
+
 func (repository *CategoryElasticRepository) GetById(ctx context.Context, id int64) (*model_repository.Category, error) {
 	return repository.BaseGenericRepository.GetById(ctx, fmt.Sprint(id), "")
 }
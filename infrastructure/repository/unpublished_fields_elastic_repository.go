@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"presentation-advert-consumer/infrastructure/configuration/custom_error"
+	"presentation-advert-consumer/infrastructure/configuration/custom_json"
+	"presentation-advert-consumer/infrastructure/configuration/elastic"
+	"presentation-advert-consumer/infrastructure/configuration/elastic/elasticv7"
+	"presentation-advert-consumer/infrastructure/configuration/log"
+	"presentation-advert-consumer/model/model_repository"
+)
+
+// UnpublishedFieldsElasticRepository keeps the unpublished fields in their own index, so they are not part of the searchable documents.
+type UnpublishedFieldsElasticRepository struct {
+	elastic.BaseGenericRepository[string, model_repository.UnpublishedFields]
+}
+
+func NewUnpublishedFieldsElasticRepository(elasticClientMap elasticv7.ClusterClientMap, clusterName string, indexName string) (*UnpublishedFieldsElasticRepository, error) {
+	if client, exists := elasticClientMap[clusterName]; exists {
+		return &UnpublishedFieldsElasticRepository{
+			BaseGenericRepository: elasticv7.NewBaseGenericRepository(client, indexName, mapToEventForUnpublishedFields, mapToIdForUnpublishedFields),
+		}, nil
+	}
+	return nil, custom_error.NewConfigNotFoundErr("elastic client not found")
+}
+
+func (repository *UnpublishedFieldsElasticRepository) GetByIds(ctx context.Context, fieldsType model_repository.UnpublishedFieldsType, ids []int64) (map[int64][]string, error) {
+	documents := make([]*elastic.GetDocument, 0, len(ids))
+	for _, id := range ids {
+		documentId := getUnpublishedFieldsId(fieldsType, id)
+		documents = append(documents, &elastic.GetDocument{Id: documentId, Routing: documentId})
+	}
+	models, err := repository.BaseGenericRepository.GetByIds(ctx, documents)
+	if err != nil {
+		log.Errorf("An error occurred when getting unpublished fields, type: %s, count: %d, err: %s", fieldsType, len(ids), err.Error())
+		return nil, err
+	}
+	result := make(map[int64][]string, len(models))
+	for _, model := range models {
+		result[model.EntityId] = model.Fields
+	}
+	return result, nil
+}
+
+func (repository *UnpublishedFieldsElasticRepository) SaveAll(ctx context.Context, fieldsType model_repository.UnpublishedFieldsType, fields map[int64][]string) map[int64]error {
+	failedIds := make(map[int64]error)
+	if len(fields) == 0 {
+		return failedIds
+	}
+	documents := make([]*elastic.IndexDocument, 0, len(fields))
+	entityIds := make(map[string]int64, len(fields))
+	for id, changedFields := range fields {
+		documentId := getUnpublishedFieldsId(fieldsType, id)
+		documents = append(documents, &elastic.IndexDocument{Id: documentId, Routing: documentId, Body: &model_repository.UnpublishedFields{
+			Type:     fieldsType,
+			EntityId: id,
+			Fields:   changedFields,
+		}})
+		entityIds[documentId] = id
+	}
+	failedDocuments, err := repository.IndexDocuments(ctx, documents)
+	if err != nil {
+		log.Errorf("An error occurred when saving unpublished fields, type: %s, count: %d, err: %s", fieldsType, len(fields), err.Error())
+		for id := range fields {
+			failedIds[id] = err
+		}
+		return failedIds
+	}
+	for documentId, err := range failedDocuments {
+		log.Errorf("An error occurred when saving unpublished fields, id: %s, err: %s", documentId, err.Error())
+		failedIds[entityIds[documentId]] = err
+	}
+	return failedIds
+}
+
+func (repository *UnpublishedFieldsElasticRepository) DeleteAll(ctx context.Context, fieldsType model_repository.UnpublishedFieldsType, ids []int64) error {
+	documents := make([]*elastic.DeleteDocument, 0, len(ids))
+	for _, id := range ids {
+		documentId := getUnpublishedFieldsId(fieldsType, id)
+		documents = append(documents, &elastic.DeleteDocument{Id: documentId, Routing: documentId})
+	}
+	failedDocuments, err := repository.DeleteDocuments(ctx, documents)
+	if err != nil {
+		log.Errorf("An error occurred when deleting unpublished fields, type: %s, count: %d, err: %s", fieldsType, len(ids), err.Error())
+		return err
+	}
+	var joinedErr error
+	for documentId, failedErr := range failedDocuments {
+		log.Errorf("An error occurred when deleting unpublished fields, id: %s, err: %s", documentId, failedErr.Error())
+		joinedErr = errors.Join(joinedErr, failedErr)
+	}
+	return joinedErr
+}
+
+func getUnpublishedFieldsId(fieldsType model_repository.UnpublishedFieldsType, id int64) string {
+	return fmt.Sprintf("%s-%d", fieldsType, id)
+}
+
+func mapToIdForUnpublishedFields(searchHit *elastic.SearchHit) (string, error) {
+	return searchHit.Id, nil
+}
+
+func mapToEventForUnpublishedFields(searchHit *elastic.SearchHit) (string, *model_repository.UnpublishedFields, error) {
+	var model model_repository.UnpublishedFields
+	if err := custom_json.Unmarshal(searchHit.Source, &model); err != nil {
+		return "", nil, err
+	}
+	return searchHit.Id, &model, nil
+}
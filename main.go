@@ -15,6 +15,7 @@ import (
 	"presentation-advert-consumer/infrastructure/configuration/server"
 	"presentation-advert-consumer/infrastructure/consumers"
 	"presentation-advert-consumer/infrastructure/handlers"
+	"presentation-advert-consumer/infrastructure/publisher"
 	"presentation-advert-consumer/infrastructure/repository"
 	"strings"
 	"syscall"
@@ -30,6 +31,7 @@ func main() {
 	consumerConfig := configreader.ReadKafkaConsumerGroupConfig("consumer-group-config")
 	clientConfigMap := configreader.ReadClientConfig("client-config")
 	elasticConfigMap := configreader.ReadElasticConfig("elastic-config")
+	publisherConfig := configreader.ReadPublisherConfig("publisher-config")
 
 	logger := log.NewLogger(logConfig.Level)
 	e.Logger = logger
@@ -48,6 +50,10 @@ func main() {
 	if err != nil {
 		e.Logger.Fatal(err)
 	}
+	unpublishedFieldsElasticRepository, err := repository.NewUnpublishedFieldsElasticRepository(elasticClientMap, "local", "unpublished-indexed-fields")
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
 
 	// Cache Service
 	categoryCacheService := cacheservice.NewCategoryCacheService(categoryElasticRepository)
@@ -59,28 +65,46 @@ func main() {
 	}
 
 	producers, err := kafka.NewProducerBuilderWithConfig(clusterConfigMap, producerTopicConfigMap).
+		WithInterceptors(kafka.DefaultProducerInterceptors("presentation-advert-consumer")...).
 		Initialize()
 	if err != nil {
 		e.Logger.Fatal(err.Error())
 	}
-	println(producers)
 
-	commandHandler, err := handlers.InitializeCommandHandler(advertApiClient, categoryElasticRepository, advertElasticRepository, categoryCacheService)
+	// Publisher
+	indexedEventPublisher, err := publisher.NewIndexedEventPublisher(producers, publisherConfig)
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+
+	commandHandler, err := handlers.InitializeCommandHandler(advertApiClient, categoryElasticRepository, advertElasticRepository, unpublishedFieldsElasticRepository, categoryCacheService, indexedEventPublisher)
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+
+	advertEventDispatcher, err := consumers.NewEventTypeDispatcher(consumerConfig, "advertUpdated")
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	categoryEventDispatcher, err := consumers.NewEventTypeDispatcher(consumerConfig, "categoryUpdated")
 	if err != nil {
 		e.Logger.Fatal(err)
 	}
 
 	consumersList := []*kafka.ConsumerGroupConsumers{
 		{
-			ConfigName: "advertUpdated",
-			Consumer:   consumers.NewAdvertEventConsumer(commandHandler),
+			ConfigName:    "advertUpdated",
+			Consumer:      consumers.NewAdvertEventConsumer(commandHandler, advertEventDispatcher),
+			BatchConsumer: consumers.NewAdvertEventBatchConsumer(commandHandler, advertEventDispatcher),
 		},
 		{
 			ConfigName: "categoryUpdated",
-			Consumer:   consumers.NewCategoryEventConsumer(commandHandler, categoryCacheService),
+			Consumer:   consumers.NewCategoryEventConsumer(commandHandler, categoryCacheService, categoryEventDispatcher),
 		},
 	}
 	consumerGroups, errorConsumers, err := kafka.NewConsumerBuilder(clusterConfigMap, consumerConfig, consumersList).
+		WithMiddlewares(kafka.DefaultConsumerMiddlewares()...).
+		WithBatchMiddlewares(kafka.DefaultBatchConsumerMiddlewares()...).
 		Initialize()
 
 	if err != nil {
@@ -93,6 +117,10 @@ func main() {
 	//HealthCheck
 	server.RegisterHealthCheck(e)
 
+	//Consumer Admin
+	server.RegisterConsumerAdmin(e, consumerGroups, errorConsumers)
+	server.RegisterErrorTopicAdmin(e, errorConsumers)
+
 	//Swagger
 	server.RegisterSwaggerRedirect(e)
 
@@ -126,6 +154,9 @@ func main() {
 				e.Logger.Error(err.Error())
 			}
 		}
+		if err := producers.Close(); err != nil {
+			e.Logger.Error(err.Error())
+		}
 		close(serverChannel)
 	}()
 	<-serverChannel
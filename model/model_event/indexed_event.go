@@ -0,0 +1,17 @@
+package model_event
+
+import "time"
+
+type AdvertIndexed struct {
+	Id            int64     `json:"id"`
+	Version       int16     `json:"version"`
+	IndexedAt     time.Time `json:"indexedAt"`
+	ChangedFields []string  `json:"changedFields"`
+}
+
+type CategoryIndexed struct {
+	Id            int64     `json:"id"`
+	Version       int16     `json:"version"`
+	IndexedAt     time.Time `json:"indexedAt"`
+	ChangedFields []string  `json:"changedFields"`
+}
@@ -0,0 +1,15 @@
+package model_repository
+
+type UnpublishedFieldsType string
+
+const (
+	AdvertUnpublishedFields   UnpublishedFieldsType = "advert"
+	CategoryUnpublishedFields UnpublishedFieldsType = "category"
+)
+
+// UnpublishedFields keeps the changed fields of a document until its indexed event is published.
+type UnpublishedFields struct {
+	Type     UnpublishedFieldsType `json:"type"`
+	EntityId int64                 `json:"entityId"`
+	Fields   []string              `json:"fields"`
+}